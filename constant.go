@@ -9,4 +9,6 @@ const (
 	ApplicationJSON               = "application/json"
 	ApplicationXWWWFormUrlencoded = "application/x-www-form-urlencoded"
 	MultipartFormData             = "multipart/form-data"
+	TextEventStream               = "text/event-stream"
+	ApplicationNDJSON             = "application/x-ndjson"
 )
@@ -6,5 +6,7 @@ import (
 
 var (
 	ErrInvalidRequestParams = NewErrorCode(1000, http.StatusBadRequest, "invalid request params")
-	ErrUnknownError = NewErrorCode(9999, http.StatusInternalServerError, "unknown server internal error")
+	ErrResourceNotFound     = NewErrorCode(1001, http.StatusNotFound, "resource not found")
+	ErrMethodNotAllowed     = NewErrorCode(1002, http.StatusMethodNotAllowed, "method not allowed")
+	ErrUnknownError         = NewErrorCode(9999, http.StatusInternalServerError, "unknown server internal error")
 )
@@ -0,0 +1,232 @@
+package ginlib
+
+import (
+	"context"
+	"fmt"
+	"github.com/gin-gonic/gin"
+	"github.com/uddmorningsun/go-livingkit/httpclient"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/readpref"
+	"net/http"
+	"sync"
+	"time"
+)
+
+const (
+	defaultCheckTimeout  = 5 * time.Second
+	defaultCheckCacheTTL = 5 * time.Second
+
+	healthStatusOK   = "ok"
+	healthStatusFail = "fail"
+)
+
+// Checker checks health of a dependency, it should return once ctx is done.
+type Checker interface {
+	Check(ctx context.Context) error
+}
+
+// CheckerFunc is an adapter to allow the use of ordinary functions as Checker.
+type CheckerFunc func(ctx context.Context) error
+
+// Check calls f(ctx).
+func (f CheckerFunc) Check(ctx context.Context) error {
+	return f(ctx)
+}
+
+// MongoChecker pings the primary of the client, e.g.: the client of mongodb.NewMongoConnection.
+func MongoChecker(client *mongo.Client) Checker {
+	return CheckerFunc(func(ctx context.Context) error {
+		return client.Ping(ctx, readpref.Primary())
+	})
+}
+
+// HTTPChecker requests GET path of the upstream, the upstream is healthy if HTTP code is [200, 400).
+func HTTPChecker(hc *httpclient.HTTPClient, path string) Checker {
+	return CheckerFunc(func(ctx context.Context) error {
+		resp, err := hc.GetWithContext(ctx, path, nil)
+		if err != nil {
+			return err
+		}
+		return hc.HandleResponse(resp, nil)
+	})
+}
+
+// DiskSpaceChecker checks the free space of the file system which path is on is at least minFreeBytes.
+func DiskSpaceChecker(path string, minFreeBytes uint64) Checker {
+	return CheckerFunc(func(context.Context) error {
+		free, err := diskFreeBytes(path)
+		if err != nil {
+			return fmt.Errorf("unable to get disk space of path: %s, error: %s", path, err)
+		}
+		if free < minFreeBytes {
+			return fmt.Errorf("disk space of path: %s is low, free: %d bytes, required: %d bytes", path, free, minFreeBytes)
+		}
+		return nil
+	})
+}
+
+// CheckOptions configures a registered Checker.
+type CheckOptions struct {
+	// Timeout of a check, default is 5s.
+	Timeout time.Duration
+	// CacheTTL caches the check result, so that frequent probes don't overload the dependency. Default is 5s, negative
+	// disables cache.
+	CacheTTL time.Duration
+}
+
+// CheckResult is the result of a check in JSON output of health endpoints.
+type CheckResult struct {
+	Status    string    `json:"status"`
+	Error     string    `json:"error,omitempty"`
+	Latency   string    `json:"latency"`
+	CheckedAt time.Time `json:"checkedAt"`
+}
+
+// HealthReport is the JSON output of health endpoints.
+type HealthReport struct {
+	Status string                 `json:"status"`
+	Checks map[string]CheckResult `json:"checks"`
+}
+
+type healthCheck struct {
+	name    string
+	checker Checker
+	opts    CheckOptions
+
+	// mu serializes checks, so that concurrent probes share the cached result.
+	mu     sync.Mutex
+	result CheckResult
+}
+
+func (check *healthCheck) run(ctx context.Context) CheckResult {
+	check.mu.Lock()
+	defer check.mu.Unlock()
+	if check.opts.CacheTTL > 0 && !check.result.CheckedAt.IsZero() && time.Since(check.result.CheckedAt) < check.opts.CacheTTL {
+		return check.result
+	}
+	checkCtx, cancel := context.WithTimeout(ctx, check.opts.Timeout)
+	defer cancel()
+	startedTime := time.Now()
+	err := check.checker.Check(checkCtx)
+	result := CheckResult{Status: healthStatusOK, Latency: time.Since(startedTime).String(), CheckedAt: startedTime}
+	if err != nil {
+		result.Status, result.Error = healthStatusFail, err.Error()
+	}
+	// Result of the canceled probe is not cached.
+	if ctx.Err() == nil {
+		check.result = result
+	}
+	return result
+}
+
+// Health serves liveness (`/healthz`) and readiness (`/readyz`) endpoints with registered checkers, see WithHealth.
+// Liveness checks tell whether the process should be restarted, so they should not check dependencies which the
+// restart can't fix. Readiness checks tell whether the process can serve requests, and liveness checks are also part
+// of readiness. It's safe for concurrent use.
+type Health struct {
+	mu        sync.RWMutex
+	liveness  []*healthCheck
+	readiness []*healthCheck
+	ready     bool
+}
+
+// NewHealth returns a Health which is ready.
+func NewHealth() *Health {
+	return &Health{ready: true}
+}
+
+func newHealthCheck(name string, checker Checker, opts CheckOptions) *healthCheck {
+	if opts.Timeout <= 0 {
+		opts.Timeout = defaultCheckTimeout
+	}
+	if opts.CacheTTL == 0 {
+		opts.CacheTTL = defaultCheckCacheTTL
+	}
+	return &healthCheck{name: name, checker: checker, opts: opts}
+}
+
+// AddLivenessCheck registers a liveness checker, e.g.: a deadlock detector.
+func (h *Health) AddLivenessCheck(name string, checker Checker, opts CheckOptions) *Health {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.liveness = append(h.liveness, newHealthCheck(name, checker, opts))
+	return h
+}
+
+// AddReadinessCheck registers a readiness checker, e.g.: MongoChecker.
+func (h *Health) AddReadinessCheck(name string, checker Checker, opts CheckOptions) *Health {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.readiness = append(h.readiness, newHealthCheck(name, checker, opts))
+	return h
+}
+
+// SetReady sets readiness manually, e.g.: not ready during graceful shutdown, or before cache warmup is done.
+func (h *Health) SetReady(ready bool) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.ready = ready
+}
+
+// Liveness runs liveness checks concurrently.
+func (h *Health) Liveness(ctx context.Context) HealthReport {
+	h.mu.RLock()
+	checks := append([]*healthCheck(nil), h.liveness...)
+	h.mu.RUnlock()
+	return runChecks(ctx, checks)
+}
+
+// Readiness runs liveness and readiness checks concurrently, it fails without checks if SetReady(false).
+func (h *Health) Readiness(ctx context.Context) HealthReport {
+	h.mu.RLock()
+	checks := append(append([]*healthCheck(nil), h.liveness...), h.readiness...)
+	ready := h.ready
+	h.mu.RUnlock()
+	if !ready {
+		return HealthReport{Status: healthStatusFail, Checks: map[string]CheckResult{}}
+	}
+	return runChecks(ctx, checks)
+}
+
+func runChecks(ctx context.Context, checks []*healthCheck) HealthReport {
+	results := make([]CheckResult, len(checks))
+	var wg sync.WaitGroup
+	for i, check := range checks {
+		wg.Add(1)
+		go func(i int, check *healthCheck) {
+			defer wg.Done()
+			results[i] = check.run(ctx)
+		}(i, check)
+	}
+	wg.Wait()
+
+	report := HealthReport{Status: healthStatusOK, Checks: make(map[string]CheckResult, len(checks))}
+	for i, check := range checks {
+		report.Checks[check.name] = results[i]
+		if results[i].Status != healthStatusOK {
+			report.Status = healthStatusFail
+		}
+	}
+	return report
+}
+
+func healthHandler(report func(ctx context.Context) HealthReport) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		result := report(c.Request.Context())
+		httpCode := http.StatusOK
+		if result.Status != healthStatusOK {
+			httpCode = http.StatusServiceUnavailable
+		}
+		ResponseOK(c, httpCode, result)
+	}
+}
+
+// LivenessHandler responds HealthReport of liveness checks, HTTP code is 503 if any check fails.
+func (h *Health) LivenessHandler() gin.HandlerFunc {
+	return healthHandler(h.Liveness)
+}
+
+// ReadinessHandler responds HealthReport of readiness checks, HTTP code is 503 if any check fails or it's not ready.
+func (h *Health) ReadinessHandler() gin.HandlerFunc {
+	return healthHandler(h.Readiness)
+}
@@ -0,0 +1,130 @@
+package ginlib
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"github.com/gin-gonic/gin"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// countingChecker counts checks, and fails if err is set.
+type countingChecker struct {
+	calls int32
+	err   atomic.Value
+}
+
+func (c *countingChecker) Check(ctx context.Context) error {
+	atomic.AddInt32(&c.calls, 1)
+	if err, ok := c.err.Load().(error); ok {
+		return err
+	}
+	return nil
+}
+
+func probe(t *testing.T, router http.Handler, path string) (int, HealthReport) {
+	t.Helper()
+	recorder := httptest.NewRecorder()
+	router.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, path, nil))
+	var report HealthReport
+	if err := json.Unmarshal(recorder.Body.Bytes(), &report); err != nil {
+		t.Fatalf("expected JSON health report, got: %s", recorder.Body.String())
+	}
+	return recorder.Code, report
+}
+
+func TestHealthEndpoints(t *testing.T) {
+	liveness, readiness := &countingChecker{}, &countingChecker{}
+	health := NewHealth().
+		AddLivenessCheck("deadlock", liveness, CheckOptions{CacheTTL: -1}).
+		AddReadinessCheck("mongo", readiness, CheckOptions{CacheTTL: -1})
+	router, err := NewGinServerWithOptions(WithMode(gin.TestMode), WithMetrics(nil, ""), WithHealth(health))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if code, report := probe(t, router, "/readyz"); code != http.StatusOK || report.Status != healthStatusOK || len(report.Checks) != 2 {
+		t.Fatalf("expected ready with liveness and readiness checks, got: %d %+v", code, report)
+	}
+	readiness.err.Store(errors.New("mongo is down"))
+	if code, report := probe(t, router, "/healthz"); code != http.StatusOK || len(report.Checks) != 1 {
+		t.Fatalf("expected liveness doesn't run readiness checks, got: %d %+v", code, report)
+	}
+	code, report := probe(t, router, "/readyz")
+	if code != http.StatusServiceUnavailable || report.Status != healthStatusFail || report.Checks["mongo"].Error != "mongo is down" {
+		t.Fatalf("expected 503 with the failed check, got: %d %+v", code, report)
+	}
+	if report.Checks["deadlock"].Status != healthStatusOK {
+		t.Fatalf("expected the other check is ok, got: %+v", report.Checks["deadlock"])
+	}
+
+	readiness.err.Store(errors.New("mongo is still down"))
+	health.SetReady(false)
+	calls := atomic.LoadInt32(&readiness.calls)
+	if code, report := probe(t, router, "/readyz"); code != http.StatusServiceUnavailable || len(report.Checks) != 0 {
+		t.Fatalf("expected 503 without checks if not ready, got: %d %+v", code, report)
+	}
+	if atomic.LoadInt32(&readiness.calls) != calls {
+		t.Fatal("expected checks are skipped if not ready")
+	}
+	if code, _ := probe(t, router, "/healthz"); code != http.StatusOK {
+		t.Fatalf("expected liveness is not affected by readiness, got: %d", code)
+	}
+}
+
+func TestHealthCheckCache(t *testing.T) {
+	checker := &countingChecker{}
+	health := NewHealth().AddReadinessCheck("cached", checker, CheckOptions{CacheTTL: 50 * time.Millisecond})
+	for i := 0; i < 3; i++ {
+		if report := health.Readiness(context.Background()); report.Status != healthStatusOK {
+			t.Fatalf("expected ok, got: %+v", report)
+		}
+	}
+	if calls := atomic.LoadInt32(&checker.calls); calls != 1 {
+		t.Fatalf("expected cached result within TTL, got %d checks", calls)
+	}
+	time.Sleep(60 * time.Millisecond)
+	checker.err.Store(errors.New("failed"))
+	if report := health.Readiness(context.Background()); report.Status != healthStatusFail || atomic.LoadInt32(&checker.calls) != 2 {
+		t.Fatalf("expected checked again after TTL, got: %+v after %d checks", report, atomic.LoadInt32(&checker.calls))
+	}
+
+	// Result of the canceled probe is not cached.
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	canceled := &countingChecker{}
+	health = NewHealth().AddReadinessCheck("canceled", canceled, CheckOptions{})
+	health.Readiness(ctx)
+	health.Readiness(context.Background())
+	if calls := atomic.LoadInt32(&canceled.calls); calls != 2 {
+		t.Fatalf("expected canceled probe is not cached, got %d checks", calls)
+	}
+}
+
+func TestHealthCheckTimeout(t *testing.T) {
+	slow := CheckerFunc(func(ctx context.Context) error {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(time.Second):
+			return nil
+		}
+	})
+	health := NewHealth().
+		AddReadinessCheck("slow", slow, CheckOptions{Timeout: 20 * time.Millisecond}).
+		AddReadinessCheck("slower", slow, CheckOptions{Timeout: 20 * time.Millisecond})
+	startedTime := time.Now()
+	report := health.Readiness(context.Background())
+	if elapsed := time.Since(startedTime); elapsed > 500*time.Millisecond {
+		t.Fatalf("expected checks run concurrently within timeout, got: %s", elapsed)
+	}
+	for _, name := range []string{"slow", "slower"} {
+		if result := report.Checks[name]; result.Error != context.DeadlineExceeded.Error() {
+			t.Fatalf("expected timeout of %s, got: %+v", name, result)
+		}
+	}
+}
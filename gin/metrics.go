@@ -0,0 +1,344 @@
+package ginlib
+
+import (
+	"bufio"
+	"fmt"
+	"github.com/gin-gonic/gin"
+	"io"
+	"math"
+	"net/http"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ContentTypeMetrics is the content type of Prometheus text exposition format.
+const ContentTypeMetrics = "text/plain; version=0.0.4; charset=utf-8"
+
+var (
+	metricNameRE = regexp.MustCompile(`^[a-zA-Z_:][a-zA-Z0-9_:]*$`)
+	labelNameRE  = regexp.MustCompile(`^[a-zA-Z_][a-zA-Z0-9_]*$`)
+	// DefaultBuckets are the default latency histogram buckets in seconds.
+	DefaultBuckets = []float64{.005, .01, .025, .05, .1, .25, .5, 1, 2.5, 5, 10}
+	// DefaultRegistry is used by NewGinServer.
+	DefaultRegistry = NewRegistry()
+)
+
+type metricType string
+
+const (
+	metricCounter   metricType = "counter"
+	metricGauge     metricType = "gauge"
+	metricHistogram metricType = "histogram"
+)
+
+// series is a metric with a set of label values.
+type series struct {
+	labelValues []string
+	value       float64
+	// bucketCounts and sum are only used by histogram.
+	bucketCounts []uint64
+	sum          float64
+}
+
+type metric struct {
+	name, help string
+	kind       metricType
+	labelNames []string
+	buckets    []float64
+
+	mu     sync.Mutex
+	series map[string]*series
+}
+
+func (m *metric) with(labelValues []string) *series {
+	if len(labelValues) != len(m.labelNames) {
+		panic(fmt.Sprintf("go-livingkit/usage: metric %s expects %d label values, got %d", m.name, len(m.labelNames), len(labelValues)))
+	}
+	key := strings.Join(labelValues, "\xff")
+	s, ok := m.series[key]
+	if !ok {
+		s = &series{labelValues: append([]string(nil), labelValues...)}
+		if m.kind == metricHistogram {
+			s.bucketCounts = make([]uint64, len(m.buckets))
+		}
+		m.series[key] = s
+	}
+	return s
+}
+
+// Registry holds metrics and writes them with Prometheus text exposition format, it's safe for concurrent use.
+type Registry struct {
+	mu      sync.Mutex
+	metrics map[string]*metric
+}
+
+// NewRegistry returns an empty Registry.
+func NewRegistry() *Registry {
+	return &Registry{metrics: make(map[string]*metric)}
+}
+
+// register returns the registered metric of name, it panics if the name is registered with another type or labels.
+func (r *Registry) register(name, help string, kind metricType, buckets []float64, labelNames []string) *metric {
+	if !metricNameRE.MatchString(name) {
+		panic(fmt.Sprintf("go-livingkit/usage: invalid metric name: %s", name))
+	}
+	for _, labelName := range labelNames {
+		if !labelNameRE.MatchString(labelName) || labelName == "le" {
+			panic(fmt.Sprintf("go-livingkit/usage: invalid label name: %s of metric %s", labelName, name))
+		}
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if m, ok := r.metrics[name]; ok {
+		if m.kind != kind || strings.Join(m.labelNames, ",") != strings.Join(labelNames, ",") {
+			panic(fmt.Sprintf("go-livingkit/usage: metric %s is registered with another type or labels", name))
+		}
+		return m
+	}
+	m := &metric{
+		name:       name,
+		help:       help,
+		kind:       kind,
+		labelNames: append([]string(nil), labelNames...),
+		buckets:    buckets,
+		series:     make(map[string]*series),
+	}
+	r.metrics[name] = m
+	return m
+}
+
+// Counter is a cumulative metric which only increases.
+type Counter struct{ metric *metric }
+
+// Counter registers a counter, the registered one is returned if it exists.
+func (r *Registry) Counter(name, help string, labelNames ...string) *Counter {
+	return &Counter{metric: r.register(name, help, metricCounter, nil, labelNames)}
+}
+
+// Add adds the non-negative value to the series of label values.
+func (c *Counter) Add(value float64, labelValues ...string) {
+	if value < 0 {
+		panic(fmt.Sprintf("go-livingkit/usage: counter %s can't decrease", c.metric.name))
+	}
+	c.metric.mu.Lock()
+	defer c.metric.mu.Unlock()
+	c.metric.with(labelValues).value += value
+}
+
+// Inc increases the series of label values by 1.
+func (c *Counter) Inc(labelValues ...string) {
+	c.Add(1, labelValues...)
+}
+
+// Gauge is a metric which can go up and down.
+type Gauge struct{ metric *metric }
+
+// Gauge registers a gauge, the registered one is returned if it exists.
+func (r *Registry) Gauge(name, help string, labelNames ...string) *Gauge {
+	return &Gauge{metric: r.register(name, help, metricGauge, nil, labelNames)}
+}
+
+// Set sets the series of label values.
+func (g *Gauge) Set(value float64, labelValues ...string) {
+	g.metric.mu.Lock()
+	defer g.metric.mu.Unlock()
+	g.metric.with(labelValues).value = value
+}
+
+// Add adds the value to the series of label values, value can be negative.
+func (g *Gauge) Add(value float64, labelValues ...string) {
+	g.metric.mu.Lock()
+	defer g.metric.mu.Unlock()
+	g.metric.with(labelValues).value += value
+}
+
+// Inc increases the series of label values by 1.
+func (g *Gauge) Inc(labelValues ...string) {
+	g.Add(1, labelValues...)
+}
+
+// Dec decreases the series of label values by 1.
+func (g *Gauge) Dec(labelValues ...string) {
+	g.Add(-1, labelValues...)
+}
+
+// Histogram counts observations in buckets.
+type Histogram struct{ metric *metric }
+
+// Histogram registers a histogram with upper bounds of buckets, default is DefaultBuckets. The registered one is
+// returned if it exists.
+func (r *Registry) Histogram(name, help string, buckets []float64, labelNames ...string) *Histogram {
+	if len(buckets) == 0 {
+		buckets = DefaultBuckets
+	}
+	buckets = append([]float64(nil), buckets...)
+	sort.Float64s(buckets)
+	return &Histogram{metric: r.register(name, help, metricHistogram, buckets, labelNames)}
+}
+
+// Observe adds an observation to the series of label values.
+func (h *Histogram) Observe(value float64, labelValues ...string) {
+	h.metric.mu.Lock()
+	defer h.metric.mu.Unlock()
+	s := h.metric.with(labelValues)
+	for i, bound := range h.metric.buckets {
+		if value <= bound {
+			s.bucketCounts[i]++
+		}
+	}
+	s.value++
+	s.sum += value
+}
+
+// snapshot copies series of the metric sorted by label values, so that it can be written without holding the lock.
+func (m *metric) snapshot() []series {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	keys := make([]string, 0, len(m.series))
+	for key := range m.series {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	snapshot := make([]series, 0, len(keys))
+	for _, key := range keys {
+		s := *m.series[key]
+		s.bucketCounts = append([]uint64(nil), s.bucketCounts...)
+		snapshot = append(snapshot, s)
+	}
+	return snapshot
+}
+
+// WriteText writes all metrics with Prometheus text exposition format, see:
+// https://prometheus.io/docs/instrumenting/exposition_formats/#text-based-format
+// Metrics are copied before writing, so that a slow writer doesn't block recording.
+func (r *Registry) WriteText(w io.Writer) error {
+	r.mu.Lock()
+	metrics := make([]*metric, 0, len(r.metrics))
+	for _, m := range r.metrics {
+		metrics = append(metrics, m)
+	}
+	r.mu.Unlock()
+	sort.Slice(metrics, func(i, j int) bool { return metrics[i].name < metrics[j].name })
+	snapshots := make([][]series, len(metrics))
+	for i, m := range metrics {
+		snapshots[i] = m.snapshot()
+	}
+
+	bw := bufio.NewWriter(w)
+	for i, m := range metrics {
+		fmt.Fprintf(bw, "# HELP %s %s\n", m.name, escapeHelp(m.help))
+		fmt.Fprintf(bw, "# TYPE %s %s\n", m.name, m.kind)
+		for _, s := range snapshots[i] {
+			labels := formatLabels(m.labelNames, s.labelValues)
+			if m.kind != metricHistogram {
+				fmt.Fprintf(bw, "%s%s %s\n", m.name, labels, formatFloat(s.value))
+				continue
+			}
+			for j, bound := range m.buckets {
+				fmt.Fprintf(bw, "%s_bucket%s %d\n", m.name, withLabel(labels, "le", formatFloat(bound)), s.bucketCounts[j])
+			}
+			fmt.Fprintf(bw, "%s_bucket%s %s\n", m.name, withLabel(labels, "le", "+Inf"), formatFloat(s.value))
+			fmt.Fprintf(bw, "%s_sum%s %s\n", m.name, labels, formatFloat(s.sum))
+			fmt.Fprintf(bw, "%s_count%s %s\n", m.name, labels, formatFloat(s.value))
+		}
+	}
+	return bw.Flush()
+}
+
+func formatFloat(value float64) string {
+	switch {
+	case math.IsInf(value, 1):
+		return "+Inf"
+	case math.IsInf(value, -1):
+		return "-Inf"
+	case math.IsNaN(value):
+		return "NaN"
+	}
+	return strconv.FormatFloat(value, 'g', -1, 64)
+}
+
+var (
+	helpEscaper       = strings.NewReplacer(`\`, `\\`, "\n", `\n`)
+	labelValueEscaper = strings.NewReplacer(`\`, `\\`, "\n", `\n`, `"`, `\"`)
+)
+
+func escapeHelp(help string) string {
+	return helpEscaper.Replace(help)
+}
+
+func formatLabels(names, values []string) string {
+	if len(names) == 0 {
+		return ""
+	}
+	pairs := make([]string, len(names))
+	for i, name := range names {
+		pairs[i] = fmt.Sprintf(`%s="%s"`, name, labelValueEscaper.Replace(values[i]))
+	}
+	return fmt.Sprintf("{%s}", strings.Join(pairs, ","))
+}
+
+func withLabel(labels, name, value string) string {
+	pair := fmt.Sprintf(`%s="%s"`, name, value)
+	if labels == "" {
+		return fmt.Sprintf("{%s}", pair)
+	}
+	return fmt.Sprintf("%s,%s}", strings.TrimSuffix(labels, "}"), pair)
+}
+
+// MetricsHandler responds all metrics of the registry with Prometheus text exposition format.
+func MetricsHandler(registry *Registry) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.Status(http.StatusOK)
+		c.Header("Content-Type", ContentTypeMetrics)
+		if err := registry.WriteText(c.Writer); err != nil {
+			_ = c.Error(err)
+		}
+	}
+}
+
+// Metrics records request metrics to the registry:
+//
+//	http_requests_total{method, route, status, code}
+//	http_request_duration_seconds{method, route, status, code}
+//	http_requests_in_flight{method, route}
+//
+// route is the route template (`c.FullPath()`), it's empty if no route matches so that unknown paths don't produce
+// unbounded series. method is `OTHER` if it's not a standard HTTP method for the same reason. code is the code of ErrorCode responded by ResponseError, it's empty if there is none.
+func Metrics(registry *Registry) gin.HandlerFunc {
+	requests := registry.Counter("http_requests_total", "Total number of HTTP requests.", "method", "route", "status", "code")
+	durations := registry.Histogram("http_request_duration_seconds", "HTTP request latency in seconds.", DefaultBuckets, "method", "route", "status", "code")
+	inflight := registry.Gauge("http_requests_in_flight", "Number of HTTP requests being served.", "method", "route")
+	return func(c *gin.Context) {
+		startedTime := time.Now()
+		method, route := metricsMethod(c.Request.Method), c.FullPath()
+		inflight.Inc(method, route)
+		defer inflight.Dec(method, route)
+
+		c.Next()
+
+		status := strconv.Itoa(c.Writer.Status())
+		var code string
+		if errorCode, ok := ErrorCodeOf(c); ok {
+			code = strconv.Itoa(errorCode.Code())
+		}
+		requests.Inc(method, route, status, code)
+		durations.Observe(time.Since(startedTime).Seconds(), method, route, status, code)
+	}
+}
+
+var standardMethods = map[string]bool{
+	http.MethodGet: true, http.MethodHead: true, http.MethodPost: true, http.MethodPut: true, http.MethodPatch: true,
+	http.MethodDelete: true, http.MethodConnect: true, http.MethodOptions: true, http.MethodTrace: true,
+}
+
+// metricsMethod maps non-standard HTTP methods to `OTHER`, since any method token is accepted by the server.
+func metricsMethod(method string) string {
+	if standardMethods[method] {
+		return method
+	}
+	return "OTHER"
+}
@@ -0,0 +1,82 @@
+package ginlib
+
+import (
+	"bytes"
+	"fmt"
+	"github.com/gin-gonic/gin"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestMetricsNonStandardMethod(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	registry := NewRegistry()
+	router := gin.New()
+	router.Use(Metrics(registry))
+	router.GET("/users/:id", func(c *gin.Context) { c.Status(http.StatusOK) })
+
+	for _, method := range []string{http.MethodGet, "PROPFIND", "X-RANDOM-1", "X-RANDOM-2"} {
+		router.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(method, "/users/1", nil))
+	}
+	var buffer bytes.Buffer
+	if err := registry.WriteText(&buffer); err != nil {
+		t.Fatal(err)
+	}
+	text := buffer.String()
+	for _, expected := range []string{
+		`http_requests_total{method="GET",route="/users/:id",status="200",code=""} 1`,
+		`http_requests_total{method="OTHER",route="",status="404",code=""} 3`,
+	} {
+		if !strings.Contains(text, expected) {
+			t.Fatalf("expected metrics: %s, got:\n%s", expected, text)
+		}
+	}
+	if strings.Contains(text, "X-RANDOM") || strings.Contains(text, "PROPFIND") {
+		t.Fatalf("expected non-standard methods are reported as OTHER, got:\n%s", text)
+	}
+}
+
+// blockingWriter blocks the first Write until release is closed.
+type blockingWriter struct {
+	writing, release chan struct{}
+	once             sync.Once
+}
+
+func (w *blockingWriter) Write(p []byte) (int, error) {
+	w.once.Do(func() { close(w.writing) })
+	<-w.release
+	return len(p), nil
+}
+
+func TestWriteTextDoesNotBlockRecording(t *testing.T) {
+	registry := NewRegistry()
+	histogram := registry.Histogram("latency_seconds", "Latency.", nil, "route")
+	// Enough series to fill the write buffer, so that writing starts before all series are formatted.
+	for i := 0; i < 20; i++ {
+		histogram.Observe(0.1, fmt.Sprintf("/route/%d", i))
+	}
+	writer := &blockingWriter{writing: make(chan struct{}), release: make(chan struct{})}
+	done := make(chan error, 1)
+	go func() { done <- registry.WriteText(writer) }()
+	<-writer.writing
+
+	observed := make(chan struct{})
+	go func() {
+		histogram.Observe(0.2, "/route/0")
+		close(observed)
+	}()
+	select {
+	case <-observed:
+	case <-time.After(time.Second):
+		close(writer.release)
+		t.Fatal("expected recording is not blocked by slow writer")
+	}
+	close(writer.release)
+	if err := <-done; err != nil {
+		t.Fatal(err)
+	}
+}
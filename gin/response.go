@@ -1,6 +1,7 @@
 package ginlib
 
 import (
+	"encoding/json"
 	"errors"
 	"fmt"
 	"github.com/gin-gonic/gin"
@@ -8,6 +9,9 @@ import (
 	"github.com/sirupsen/logrus"
 )
 
+// ContextKeyErrorCode is the gin.Context key of ErrorCode responded by ResponseError, see ErrorCodeOf.
+const ContextKeyErrorCode = "go-livingkit/errorCode"
+
 // ResponseOK will write valid JSON response.
 func ResponseOK(c *gin.Context, httpCode int, data interface{}) {
 	if data == nil {
@@ -38,6 +42,7 @@ func ResponseError(c *gin.Context, jsonObj interface{}, httpCode ...int) {
 				logrus.Errorf("Underlying error: %+v", err)
 			}
 		}
+		c.Set(ContextKeyErrorCode, code)
 		c.AbortWithStatusJSON(code.httpCode, code)
 		return
 	}
@@ -55,6 +60,16 @@ type ErrorCode struct {
 	err                error
 }
 
+// ErrorCodeOf returns the ErrorCode responded by ResponseError, e.g.: report it in middleware after `c.Next()`.
+func ErrorCodeOf(c *gin.Context) (ErrorCode, bool) {
+	value, ok := c.Get(ContextKeyErrorCode)
+	if !ok {
+		return ErrorCode{}, false
+	}
+	code, ok := value.(ErrorCode)
+	return code, ok
+}
+
 // NewErrorCode will new specific error code with customizable code number, HTTPCode and message.
 // Default delimiter is blank space.
 func NewErrorCode(code, httpCode int, message string) ErrorCode {
@@ -85,6 +100,26 @@ func (ec ErrorCode) WithError(myerr error) ErrorCode {
 	return ec
 }
 
+// Code returns the code number.
+func (ec ErrorCode) Code() int {
+	return ec.code
+}
+
+// HTTPCode returns the HTTP status code.
+func (ec ErrorCode) HTTPCode() int {
+	return ec.httpCode
+}
+
+// MarshalJSON writes the error response body, field names follow `success` and `message` fields parsed by
+// httpclient.HTTPError, e.g.: `{"success": false, "code": 1000, "message": "invalid request params"}`.
+func (ec ErrorCode) MarshalJSON() ([]byte, error) {
+	return json.Marshal(struct {
+		Success bool   `json:"success"`
+		Code    int    `json:"code"`
+		Message string `json:"message"`
+	}{Code: ec.code, Message: ec.message})
+}
+
 func (ec ErrorCode) String() string {
 	return ec.message
 }
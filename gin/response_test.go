@@ -0,0 +1,26 @@
+package ginlib
+
+import (
+	"github.com/gin-gonic/gin"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestResponseErrorBody(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.GET("/users/:id", func(c *gin.Context) {
+		ResponseError(c, ErrResourceNotFound.WithMessage("user", false))
+	})
+
+	recorder := httptest.NewRecorder()
+	router.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/users/1", nil))
+	if recorder.Code != http.StatusNotFound {
+		t.Fatalf("expected 404, got: %d", recorder.Code)
+	}
+	expected := `{"success":false,"code":1001,"message":"` + ErrResourceNotFound.WithMessage("user", false).String() + `"}`
+	if actual := recorder.Body.String(); actual != expected {
+		t.Fatalf("expected error body: %s, got: %s", expected, actual)
+	}
+}
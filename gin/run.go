@@ -0,0 +1,313 @@
+package ginlib
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"github.com/sirupsen/logrus"
+	"net"
+	"net/http"
+	"os"
+	"os/signal"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+)
+
+const (
+	defaultServerAddress   = ":8080"
+	defaultShutdownTimeout = 30 * time.Second
+	defaultHookTimeout     = 10 * time.Second
+)
+
+// ServerOption configures a Server.
+type ServerOption func(*Server) error
+
+type shutdownHook struct {
+	name string
+	hook func(ctx context.Context) error
+}
+
+// Server runs http.Server of the handler (e.g.: NewGinServer) with graceful shutdown, see Server.Run.
+type Server struct {
+	server          *http.Server
+	address         string
+	unixSocket      string
+	certFile        string
+	keyFile         string
+	shutdownTimeout time.Duration
+	hookTimeout     time.Duration
+	drainDelay      time.Duration
+	signals         []os.Signal
+	health          *Health
+
+	mu    sync.Mutex
+	hooks []shutdownHook
+	// shutdownRequested is closed by Shutdown, it's kept so that Shutdown before Run also stops Run.
+	shutdownRequested chan struct{}
+	shutdownOnce      sync.Once
+}
+
+// WithAddress listens on the TCP address, default is `:8080`.
+func WithAddress(address string) ServerOption {
+	return func(s *Server) error {
+		s.address = address
+		return nil
+	}
+}
+
+// WithUnixSocket listens on the unix socket instead of TCP address, the stale socket file is removed before listening.
+func WithUnixSocket(path string) ServerOption {
+	return func(s *Server) error {
+		if path == "" {
+			return fmt.Errorf("required unix socket path")
+		}
+		s.unixSocket = path
+		return nil
+	}
+}
+
+// WithTimeouts sets read, write and idle timeout of http.Server, zero means no timeout.
+func WithTimeouts(read, write, idle time.Duration) ServerOption {
+	return func(s *Server) error {
+		if read < 0 || write < 0 || idle < 0 {
+			return fmt.Errorf("timeout should not be negative")
+		}
+		s.server.ReadTimeout, s.server.WriteTimeout, s.server.IdleTimeout = read, write, idle
+		return nil
+	}
+}
+
+// WithReadHeaderTimeout sets timeout of reading request headers, default is the read timeout.
+func WithReadHeaderTimeout(timeout time.Duration) ServerOption {
+	return func(s *Server) error {
+		if timeout < 0 {
+			return fmt.Errorf("timeout should not be negative")
+		}
+		s.server.ReadHeaderTimeout = timeout
+		return nil
+	}
+}
+
+// WithShutdownTimeout sets the deadline of draining in-flight requests, default is 30s.
+func WithShutdownTimeout(timeout time.Duration) ServerOption {
+	return func(s *Server) error {
+		if timeout <= 0 {
+			return fmt.Errorf("shutdown timeout should be greater than 0")
+		}
+		s.shutdownTimeout = timeout
+		return nil
+	}
+}
+
+// WithShutdownHookTimeout sets the timeout of each shutdown hook, default is 10s.
+func WithShutdownHookTimeout(timeout time.Duration) ServerOption {
+	return func(s *Server) error {
+		if timeout <= 0 {
+			return fmt.Errorf("shutdown hook timeout should be greater than 0")
+		}
+		s.hookTimeout = timeout
+		return nil
+	}
+}
+
+// WithDrainDelay keeps serving requests for the delay after readiness flips to not-ready, so that load balancers
+// (e.g.: Kubernetes endpoints) stop sending new requests before the listener is closed. It's not part of shutdown
+// timeout, and a second shutdown signal skips the rest of the delay.
+func WithDrainDelay(delay time.Duration) ServerOption {
+	return func(s *Server) error {
+		if delay < 0 {
+			return fmt.Errorf("drain delay should not be negative")
+		}
+		s.drainDelay = delay
+		return nil
+	}
+}
+
+// WithTLS serves HTTPS with the certificate and key files.
+func WithTLS(certFile, keyFile string) ServerOption {
+	return func(s *Server) error {
+		if certFile == "" || keyFile == "" {
+			return fmt.Errorf("required certificate and key file")
+		}
+		s.certFile, s.keyFile = certFile, keyFile
+		return nil
+	}
+}
+
+// WithTLSConfig serves HTTPS with the TLS config, certificates can be set by the config or WithTLS.
+func WithTLSConfig(cfg *tls.Config) ServerOption {
+	return func(s *Server) error {
+		if cfg == nil {
+			return fmt.Errorf("nil TLS config")
+		}
+		s.server.TLSConfig = cfg
+		return nil
+	}
+}
+
+// WithReadiness sets the health not-ready when shutdown starts, e.g.: the Health of WithHealth.
+func WithReadiness(health *Health) ServerOption {
+	return func(s *Server) error {
+		if health == nil {
+			return fmt.Errorf("nil health")
+		}
+		s.health = health
+		return nil
+	}
+}
+
+// WithSignals replaces the shutdown signals, default is SIGINT and SIGTERM.
+func WithSignals(signals ...os.Signal) ServerOption {
+	return func(s *Server) error {
+		s.signals = signals
+		return nil
+	}
+}
+
+// NewServer returns a Server of the handler with series of ServerOption.
+func NewServer(handler http.Handler, opts ...ServerOption) (*Server, error) {
+	s := &Server{
+		server:            &http.Server{Handler: handler},
+		address:           defaultServerAddress,
+		shutdownTimeout:   defaultShutdownTimeout,
+		hookTimeout:       defaultHookTimeout,
+		signals:           []os.Signal{os.Interrupt, syscall.SIGTERM},
+		shutdownRequested: make(chan struct{}),
+	}
+	for _, opt := range opts {
+		if err := opt(s); err != nil {
+			return nil, fmt.Errorf("unable apply option, error: %s", err)
+		}
+	}
+	return s, nil
+}
+
+// OnShutdown registers a hook which runs after in-flight requests are drained, e.g.: close Mongo clients, flush logs.
+// Hooks run in registration order, each with its own context of shutdown hook timeout (see WithShutdownHookTimeout),
+// failed hooks don't stop the others.
+func (s *Server) OnShutdown(name string, hook func(ctx context.Context) error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.hooks = append(s.hooks, shutdownHook{name: name, hook: hook})
+}
+
+// Run serves until ctx is done, a shutdown signal is received or Shutdown is called, then it shuts down gracefully:
+// sets readiness not-ready, waits for drain delay, drains in-flight requests within shutdown timeout and runs shutdown
+// hooks. It returns nil if shutdown is done in time.
+func (s *Server) Run(ctx context.Context) error {
+	// Buffered for the second signal which skips drain delay.
+	signals := make(chan os.Signal, 2)
+	if len(s.signals) > 0 {
+		signal.Notify(signals, s.signals...)
+		defer signal.Stop(signals)
+	}
+	listener, err := s.listen()
+	if err != nil {
+		return err
+	}
+
+	serveErr := make(chan error, 1)
+	go func() {
+		if s.certFile != "" || s.server.TLSConfig != nil {
+			serveErr <- s.server.ServeTLS(listener, s.certFile, s.keyFile)
+			return
+		}
+		serveErr <- s.server.Serve(listener)
+	}()
+	logrus.Infof("server is listening on: %s", listener.Addr())
+
+	var runErr error
+	select {
+	case err := <-serveErr:
+		runErr = fmt.Errorf("server stopped unexpectedly, error: %w", err)
+	case <-ctx.Done():
+		logrus.Infof("server is shutting down since context is done")
+	case sig := <-signals:
+		logrus.Infof("server is shutting down since signal: %s", sig)
+	case <-s.shutdownRequested:
+		logrus.Infof("server is shutting down since Shutdown is called")
+	}
+	if err := s.shutdown(signals); err != nil && runErr == nil {
+		runErr = err
+	}
+	return runErr
+}
+
+// Shutdown stops Run gracefully, Run returns right after listening if Shutdown is called before it.
+func (s *Server) Shutdown() {
+	s.shutdownOnce.Do(func() { close(s.shutdownRequested) })
+}
+
+func (s *Server) listen() (net.Listener, error) {
+	if s.unixSocket == "" {
+		listener, err := net.Listen("tcp", s.address)
+		if err != nil {
+			return nil, fmt.Errorf("unable to listen on: %s, error: %s", s.address, err)
+		}
+		return listener, nil
+	}
+	if err := os.Remove(s.unixSocket); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return nil, fmt.Errorf("unable to remove stale unix socket: %s, error: %s", s.unixSocket, err)
+	}
+	listener, err := net.Listen("unix", s.unixSocket)
+	if err != nil {
+		return nil, fmt.Errorf("unable to listen on unix socket: %s, error: %s", s.unixSocket, err)
+	}
+	return listener, nil
+}
+
+// shutdown drains in-flight requests and runs shutdown hooks, a signal received during drain delay skips the rest of it.
+func (s *Server) shutdown(signals <-chan os.Signal) error {
+	if s.health != nil {
+		s.health.SetReady(false)
+	}
+	if s.drainDelay > 0 {
+		timer := time.NewTimer(s.drainDelay)
+		select {
+		case <-timer.C:
+		case sig := <-signals:
+			logrus.Infof("drain delay is skipped since signal: %s", sig)
+		}
+		timer.Stop()
+	}
+
+	var failures []string
+	ctx, cancel := context.WithTimeout(context.Background(), s.shutdownTimeout)
+	if err := s.server.Shutdown(ctx); err != nil {
+		failures = append(failures, fmt.Sprintf("drain requests: %s", err))
+		_ = s.server.Close()
+	}
+	cancel()
+	s.mu.Lock()
+	hooks := append([]shutdownHook(nil), s.hooks...)
+	s.mu.Unlock()
+	for _, hook := range hooks {
+		if err := s.runHook(hook); err != nil {
+			logrus.Errorf("shutdown hook: %s failed, error: %s", hook.name, err)
+			failures = append(failures, fmt.Sprintf("%s: %s", hook.name, err))
+		}
+	}
+	if len(failures) > 0 {
+		return fmt.Errorf("server shutdown failed, error: %s", strings.Join(failures, "; "))
+	}
+	logrus.Infof("server is shut down")
+	return nil
+}
+
+func (s *Server) runHook(hook shutdownHook) error {
+	ctx, cancel := context.WithTimeout(context.Background(), s.hookTimeout)
+	defer cancel()
+	return hook.hook(ctx)
+}
+
+// Run runs a Server of the handler until SIGINT or SIGTERM, see Server.Run.
+func Run(handler http.Handler, opts ...ServerOption) error {
+	s, err := NewServer(handler, opts...)
+	if err != nil {
+		return err
+	}
+	return s.Run(context.Background())
+}
@@ -0,0 +1,129 @@
+package ginlib
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"syscall"
+	"testing"
+	"time"
+)
+
+// startServer runs the server on a unix socket until it accepts connections, the returned channel receives Run error.
+func startServer(t *testing.T, handler http.Handler, opts ...ServerOption) (*Server, <-chan error) {
+	t.Helper()
+	socket := filepath.Join(t.TempDir(), "server.sock")
+	s, err := NewServer(handler, append([]ServerOption{WithUnixSocket(socket)}, opts...)...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	done := make(chan error, 1)
+	go func() { done <- s.Run(context.Background()) }()
+	for deadline := time.Now().Add(time.Second); ; time.Sleep(5 * time.Millisecond) {
+		if conn, err := net.Dial("unix", socket); err == nil {
+			conn.Close()
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("expected server is listening")
+		}
+	}
+	return s, done
+}
+
+func waitRun(t *testing.T, done <-chan error) error {
+	t.Helper()
+	select {
+	case err := <-done:
+		return err
+	case <-time.After(2 * time.Second):
+		t.Fatal("expected Run returns")
+		return nil
+	}
+}
+
+func TestServerShutdownBeforeRun(t *testing.T) {
+	s, err := NewServer(http.NotFoundHandler(), WithUnixSocket(filepath.Join(t.TempDir(), "server.sock")))
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.Shutdown()
+	s.Shutdown()
+	done := make(chan error, 1)
+	go func() { done <- s.Run(context.Background()) }()
+	if err := waitRun(t, done); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestServerShutdown(t *testing.T) {
+	health := NewHealth()
+	var hooks []string
+	s, done := startServer(t, http.NotFoundHandler(), WithReadiness(health))
+	s.OnShutdown("first", func(ctx context.Context) error {
+		hooks = append(hooks, "first")
+		return nil
+	})
+	s.OnShutdown("second", func(ctx context.Context) error {
+		hooks = append(hooks, "second")
+		return errors.New("flush failed")
+	})
+	s.Shutdown()
+	err := waitRun(t, done)
+	if err == nil || !strings.Contains(err.Error(), "second: flush failed") {
+		t.Fatalf("expected error of the failed hook, got: %v", err)
+	}
+	if strings.Join(hooks, ",") != "first,second" {
+		t.Fatalf("expected hooks run in registration order, got: %v", hooks)
+	}
+	if report := health.Readiness(context.Background()); report.Status != healthStatusFail {
+		t.Fatalf("expected not ready after shutdown, got: %s", report.Status)
+	}
+}
+
+func TestServerShutdownHookTimeout(t *testing.T) {
+	var secondErr error
+	s, done := startServer(t, http.NotFoundHandler(), WithShutdownHookTimeout(20*time.Millisecond))
+	s.OnShutdown("slow", func(ctx context.Context) error {
+		<-ctx.Done()
+		return ctx.Err()
+	})
+	s.OnShutdown("fast", func(ctx context.Context) error {
+		secondErr = ctx.Err()
+		return nil
+	})
+	s.Shutdown()
+	err := waitRun(t, done)
+	if err == nil || !strings.Contains(err.Error(), "slow: "+context.DeadlineExceeded.Error()) {
+		t.Fatalf("expected timeout of the slow hook, got: %v", err)
+	}
+	if secondErr != nil {
+		t.Fatalf("expected each hook has its own timeout, got: %v", secondErr)
+	}
+}
+
+func TestServerSecondSignalSkipsDrainDelay(t *testing.T) {
+	health := NewHealth()
+	_, done := startServer(t, http.NotFoundHandler(), WithReadiness(health), WithDrainDelay(time.Hour),
+		WithSignals(syscall.SIGUSR1))
+	if err := syscall.Kill(os.Getpid(), syscall.SIGUSR1); err != nil {
+		t.Fatal(err)
+	}
+	// Readiness is set not-ready once shutdown starts, the second signal is sent during drain delay then.
+	for deadline := time.Now().Add(time.Second); health.Readiness(context.Background()).Status == healthStatusOK; {
+		if time.Now().After(deadline) {
+			t.Fatal("expected shutdown starts by the first signal")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	if err := syscall.Kill(os.Getpid(), syscall.SIGUSR1); err != nil {
+		t.Fatal(err)
+	}
+	if err := waitRun(t, done); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -1,21 +1,228 @@
 package ginlib
 
 import (
+	"fmt"
 	"github.com/gin-gonic/gin"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/trace"
 )
 
+// Option configures the gin.Engine of NewGinServerWithOptions.
+type Option func(*serverOptions) error
+
+type serverOptions struct {
+	mode                   string
+	trustedProxies         []string
+	setTrustedProxies      bool
+	middleware             []gin.HandlerFunc
+	recovery               gin.HandlerFunc
+	apisPath               string
+	notFound               ErrorCode
+	methodNotAllowed       ErrorCode
+	handleMethodNotAllowed bool
+	redirectTrailingSlash  bool
+	maxMultipartMemory     int64
+	metrics                *Registry
+	metricsPath            string
+	tracing                gin.HandlerFunc
+	health                 *Health
+}
+
+// WithMode sets gin mode, one of gin.DebugMode, gin.ReleaseMode and gin.TestMode. NOTE: gin mode is global.
+func WithMode(mode string) Option {
+	return func(o *serverOptions) error {
+		switch mode {
+		case gin.DebugMode, gin.ReleaseMode, gin.TestMode:
+			o.mode = mode
+			return nil
+		}
+		return fmt.Errorf("invalid gin mode: %s", mode)
+	}
+}
+
+// WithTrustedProxies sets network origins (IPv4/IPv6 addresses or CIDRs) of proxies whose client IP headers
+// (e.g.: `X-Forwarded-For`) are trusted by `c.ClientIP()`, nil trusts none.
+func WithTrustedProxies(proxies []string) Option {
+	return func(o *serverOptions) error {
+		o.trustedProxies, o.setTrustedProxies = proxies, true
+		return nil
+	}
+}
+
+// WithMiddleware appends middleware after the built-in ones (tracing, metrics and recovery), so that panics of them are
+// recovered. Use WithTracing rather than WithMiddleware(Tracing(...)) to record panics in spans.
+func WithMiddleware(middleware ...gin.HandlerFunc) Option {
+	return func(o *serverOptions) error {
+		for _, handler := range middleware {
+			if handler == nil {
+				return fmt.Errorf("nil middleware")
+			}
+		}
+		o.middleware = append(o.middleware, middleware...)
+		return nil
+	}
+}
+
+// WithRecovery replaces the recovery middleware, default is RecoverJSONResponse(nil). Nil disables recovery.
+func WithRecovery(recovery gin.HandlerFunc) Option {
+	return func(o *serverOptions) error {
+		o.recovery = recovery
+		return nil
+	}
+}
+
+// WithAPIs mounts APIs at path, default is `/apis`. Empty path disables it.
+func WithAPIs(path string) Option {
+	return func(o *serverOptions) error {
+		o.apisPath = path
+		return nil
+	}
+}
+
+// WithNotFound responds the error code if no route matches, default is ErrResourceNotFound.
+func WithNotFound(code ErrorCode) Option {
+	return func(o *serverOptions) error {
+		o.notFound = code
+		return nil
+	}
+}
+
+// WithMethodNotAllowed responds the error code if the route matches but the method doesn't, default is
+// ErrMethodNotAllowed. It's enabled by default, see WithHandleMethodNotAllowed.
+func WithMethodNotAllowed(code ErrorCode) Option {
+	return func(o *serverOptions) error {
+		o.methodNotAllowed = code
+		return nil
+	}
+}
+
+// WithHandleMethodNotAllowed sets gin.Engine.HandleMethodNotAllowed, default is true. If it's false, the request of
+// mismatched method is responded as not found.
+func WithHandleMethodNotAllowed(enabled bool) Option {
+	return func(o *serverOptions) error {
+		o.handleMethodNotAllowed = enabled
+		return nil
+	}
+}
+
+// WithRedirectTrailingSlash sets gin.Engine.RedirectTrailingSlash, default is false.
+func WithRedirectTrailingSlash(enabled bool) Option {
+	return func(o *serverOptions) error {
+		o.redirectTrailingSlash = enabled
+		return nil
+	}
+}
+
+// WithMaxMultipartMemory sets memory limit of parsing multipart form, the rest of files are stored in temporary files.
+// Default is 32 MiB.
+func WithMaxMultipartMemory(size int64) Option {
+	return func(o *serverOptions) error {
+		if size <= 0 {
+			return fmt.Errorf("max multipart memory should be greater than 0")
+		}
+		o.maxMultipartMemory = size
+		return nil
+	}
+}
+
+// WithMetrics records request metrics to the registry (see Metrics), and serves them at path. Empty path only records
+// metrics without the endpoint, e.g.: metrics are served on another port. Nil registry disables metrics.
+// Default is DefaultRegistry and `/metrics`.
+func WithMetrics(registry *Registry, path string) Option {
+	return func(o *serverOptions) error {
+		o.metrics, o.metricsPath = registry, path
+		return nil
+	}
+}
+
+// WithTracing traces requests with Tracing middleware, it's outside of recovery so that the span of panic is ended with
+// 500 and error status.
+func WithTracing(provider trace.TracerProvider, propagator propagation.TextMapPropagator) Option {
+	return func(o *serverOptions) error {
+		o.tracing = Tracing(provider, propagator)
+		return nil
+	}
+}
+
+// WithHealth serves liveness checks at `/healthz` and readiness checks at `/readyz` of the health.
+func WithHealth(health *Health) Option {
+	return func(o *serverOptions) error {
+		if health == nil {
+			return fmt.Errorf("nil health")
+		}
+		o.health = health
+		return nil
+	}
+}
+
 // NewGinServer returns a gin.Engine instance with the series of middleware.
 func NewGinServer() *gin.Engine {
-	// gin.DisableConsoleColor()
-	// gin.SetMode(gin.ReleaseMode)
+	engine, _ := NewGinServerWithOptions()
+	return engine
+}
+
+// NewGinServerWithOptions returns a gin.Engine instance with the series of middleware and Option.
+func NewGinServerWithOptions(opts ...Option) (*gin.Engine, error) {
+	o := &serverOptions{
+		recovery:               RecoverJSONResponse(nil),
+		apisPath:               "/apis",
+		notFound:               ErrResourceNotFound,
+		methodNotAllowed:       ErrMethodNotAllowed,
+		handleMethodNotAllowed: true,
+		metrics:                DefaultRegistry,
+		metricsPath:            "/metrics",
+	}
+	for _, opt := range opts {
+		if err := opt(o); err != nil {
+			return nil, fmt.Errorf("unable apply option, error: %s", err)
+		}
+	}
+
+	if o.mode != "" {
+		gin.SetMode(o.mode)
+	}
 	engine := gin.New()
 	{
-		engine.HandleMethodNotAllowed = true
-		engine.RedirectTrailingSlash = false
-		engine.Use(
-			RecoverJSONResponse(nil),
-		)
-		engine.GET("/apis", APIs(engine))
+		engine.HandleMethodNotAllowed = o.handleMethodNotAllowed
+		engine.RedirectTrailingSlash = o.redirectTrailingSlash
+		if o.maxMultipartMemory > 0 {
+			engine.MaxMultipartMemory = o.maxMultipartMemory
+		}
+		if o.setTrustedProxies {
+			if err := engine.SetTrustedProxies(o.trustedProxies); err != nil {
+				return nil, fmt.Errorf("invalid trusted proxies, error: %s", err)
+			}
+		}
+		// Tracing and metrics are outside of recovery so that the status of panic is recorded.
+		if o.tracing != nil {
+			engine.Use(o.tracing)
+		}
+		if o.metrics != nil {
+			engine.Use(Metrics(o.metrics))
+		}
+		if o.recovery != nil {
+			engine.Use(o.recovery)
+		}
+		engine.Use(o.middleware...)
+		engine.NoRoute(errorCodeHandler(o.notFound))
+		engine.NoMethod(errorCodeHandler(o.methodNotAllowed))
+
+		if o.apisPath != "" {
+			engine.GET(o.apisPath, APIs(engine))
+		}
+		if o.metrics != nil && o.metricsPath != "" {
+			engine.GET(o.metricsPath, MetricsHandler(o.metrics))
+		}
+		if o.health != nil {
+			engine.GET("/healthz", o.health.LivenessHandler())
+			engine.GET("/readyz", o.health.ReadinessHandler())
+		}
+	}
+	return engine, nil
+}
+
+func errorCodeHandler(code ErrorCode) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ResponseError(c, code)
 	}
-	return engine
 }
@@ -0,0 +1,68 @@
+package ginlib
+
+import (
+	"fmt"
+	"github.com/gin-gonic/gin"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	semconv "go.opentelemetry.io/otel/semconv/v1.17.0"
+	"go.opentelemetry.io/otel/trace"
+	"net/http"
+)
+
+const tracerName = "github.com/uddmorningsun/go-livingkit/gin"
+
+// AttributeErrorCode is the span attribute of ErrorCode responded by ResponseError.
+const AttributeErrorCode = attribute.Key("livingkit.error_code")
+
+// Tracing starts a server span for every request with trace context extracted from W3C `traceparent` and `tracestate`
+// headers, the span context is set to `c.Request.Context()` so that outbound HTTPClient calls with the context are
+// traced as child spans. Nil provider uses otel.GetTracerProvider(), and nil propagator uses propagation.TraceContext.
+// The span has attributes of route (`c.FullPath()`), status and the code of ErrorCode. It should be used outside of
+// recovery middleware (see WithTracing), otherwise the span of panic is ended without error status.
+func Tracing(provider trace.TracerProvider, propagator propagation.TextMapPropagator) gin.HandlerFunc {
+	if provider == nil {
+		provider = otel.GetTracerProvider()
+	}
+	if propagator == nil {
+		propagator = propagation.TraceContext{}
+	}
+	tracer := provider.Tracer(tracerName)
+	return func(c *gin.Context) {
+		route := c.FullPath()
+		name := fmt.Sprintf("HTTP %s", c.Request.Method)
+		if route != "" {
+			name = fmt.Sprintf("%s %s", c.Request.Method, route)
+		}
+		ctx := propagator.Extract(c.Request.Context(), propagation.HeaderCarrier(c.Request.Header))
+		ctx, span := tracer.Start(ctx, name,
+			trace.WithSpanKind(trace.SpanKindServer),
+			trace.WithAttributes(
+				semconv.HTTPMethodKey.String(c.Request.Method),
+				semconv.HTTPTargetKey.String(c.Request.URL.RequestURI()),
+				semconv.HTTPClientIPKey.String(c.ClientIP()),
+			),
+		)
+		defer span.End()
+		if route != "" {
+			span.SetAttributes(semconv.HTTPRouteKey.String(route))
+		}
+		c.Request = c.Request.WithContext(ctx)
+
+		c.Next()
+
+		status := c.Writer.Status()
+		span.SetAttributes(semconv.HTTPStatusCodeKey.Int(status))
+		if code, ok := ErrorCodeOf(c); ok {
+			span.SetAttributes(AttributeErrorCode.Int(code.Code()))
+		}
+		if len(c.Errors) > 0 {
+			span.RecordError(c.Errors.Last())
+		}
+		if status >= http.StatusInternalServerError {
+			span.SetStatus(codes.Error, http.StatusText(status))
+		}
+	}
+}
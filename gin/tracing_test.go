@@ -0,0 +1,64 @@
+package ginlib
+
+import (
+	"github.com/gin-gonic/gin"
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	semconv "go.opentelemetry.io/otel/semconv/v1.17.0"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestTracingRecordsPanic(t *testing.T) {
+	exporter := tracetest.NewInMemoryExporter()
+	router, err := NewGinServerWithOptions(
+		WithMode(gin.TestMode),
+		WithMetrics(nil, ""),
+		WithTracing(sdktrace.NewTracerProvider(sdktrace.WithSyncer(exporter)), nil),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	router.GET("/panic/:id", func(c *gin.Context) { panic("boom") })
+	router.GET("/users/:id", func(c *gin.Context) { ResponseError(c, ErrResourceNotFound) })
+
+	req := httptest.NewRequest(http.MethodGet, "/panic/1", nil)
+	req.Header.Set("traceparent", "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+	recorder := httptest.NewRecorder()
+	router.ServeHTTP(recorder, req)
+	router.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/users/1", nil))
+	if recorder.Code != http.StatusInternalServerError {
+		t.Fatalf("expected panic is recovered as 500, got: %d", recorder.Code)
+	}
+
+	spans := exporter.GetSpans()
+	if len(spans) != 2 {
+		t.Fatalf("expected 2 spans, got: %d", len(spans))
+	}
+	panicked := spans[0]
+	if panicked.Name != "GET /panic/:id" || panicked.Status.Code != codes.Error {
+		t.Fatalf("expected error span of panic, got: %s %v", panicked.Name, panicked.Status)
+	}
+	if panicked.Parent.TraceID().String() != "4bf92f3577b34da6a3ce929d0e0e4736" {
+		t.Fatalf("expected span of the extracted trace context, got parent: %s", panicked.Parent.TraceID())
+	}
+	attributes := map[string]string{}
+	for _, attribute := range spans[1].Attributes {
+		attributes[string(attribute.Key)] = attribute.Value.Emit()
+	}
+	expected := map[string]string{
+		string(semconv.HTTPStatusCodeKey): "404",
+		string(semconv.HTTPRouteKey):      "/users/:id",
+		string(AttributeErrorCode):        "1001",
+	}
+	for key, value := range expected {
+		if attributes[key] != value {
+			t.Fatalf("expected attribute %s: %s, got: %s", key, value, attributes[key])
+		}
+	}
+	if spans[1].Status.Code == codes.Error {
+		t.Fatalf("expected 4xx is not error status, got: %v", spans[1].Status)
+	}
+}
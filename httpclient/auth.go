@@ -0,0 +1,134 @@
+package httpclient
+
+import (
+	"fmt"
+	"github.com/uddmorningsun/go-livingkit/oauth2/clientcredentials"
+	"net/http"
+)
+
+const headerAuthorization = "Authorization"
+
+// AuthProvider attaches credentials to every request of HTTPClient, see WithAuth.
+type AuthProvider interface {
+	Authenticate(req *http.Request) error
+}
+
+// AuthProviderFunc is an adapter to allow the use of ordinary functions as AuthProvider.
+type AuthProviderFunc func(req *http.Request) error
+
+// Authenticate calls f(req).
+func (f AuthProviderFunc) Authenticate(req *http.Request) error {
+	return f(req)
+}
+
+// invalidator is implemented by AuthProvider which caches credentials, the request will be retried once with new
+// credentials if server responds 401.
+type invalidator interface {
+	Invalidate()
+}
+
+// WithAuth attaches credentials of the given provider to every request.
+func WithAuth(provider AuthProvider) Option {
+	return func(hc *HTTPClient) error {
+		if provider == nil {
+			return fmt.Errorf("nil auth provider")
+		}
+		hc.auth = provider
+		return nil
+	}
+}
+
+// BearerToken sets static `Authorization: Bearer <token>` header.
+func BearerToken(token string) AuthProvider {
+	return AuthProviderFunc(func(req *http.Request) error {
+		req.Header.Set(headerAuthorization, fmt.Sprintf("Bearer %s", token))
+		return nil
+	})
+}
+
+// BasicAuth sets HTTP basic authentication header.
+func BasicAuth(username, password string) AuthProvider {
+	return AuthProviderFunc(func(req *http.Request) error {
+		req.SetBasicAuth(username, password)
+		return nil
+	})
+}
+
+// APIKeyHeader sets API key to the given header, e.g.: `X-Api-Key`.
+func APIKeyHeader(header, key string) AuthProvider {
+	return AuthProviderFunc(func(req *http.Request) error {
+		req.Header.Set(header, key)
+		return nil
+	})
+}
+
+// APIKeyQuery sets API key to the given URL query param.
+func APIKeyQuery(param, key string) AuthProvider {
+	return AuthProviderFunc(func(req *http.Request) error {
+		query := req.URL.Query()
+		query.Set(param, key)
+		req.URL.RawQuery = query.Encode()
+		return nil
+	})
+}
+
+type oauth2ClientCredentials struct {
+	source *clientcredentials.TokenSource
+}
+
+// OAuth2ClientCredentials sets the access token of client credentials flow, token is cached and refreshed before
+// expiry, and the request is retried once with a new token if server responds 401.
+func OAuth2ClientCredentials(cfg *clientcredentials.Config) (AuthProvider, error) {
+	if cfg == nil {
+		return nil, fmt.Errorf("nil oauth2 client credentials config")
+	}
+	if cfg.TokenURL == "" {
+		return nil, fmt.Errorf("required oauth2 token url")
+	}
+	return &oauth2ClientCredentials{source: cfg.TokenSource()}, nil
+}
+
+func (o *oauth2ClientCredentials) Authenticate(req *http.Request) error {
+	token, err := o.source.Token(req.Context())
+	if err != nil {
+		return fmt.Errorf("unable to get oauth2 token, error: %w", err)
+	}
+	req.Header.Set(headerAuthorization, fmt.Sprintf("%s %s", token.Type(), token.AccessToken))
+	return nil
+}
+
+func (o *oauth2ClientCredentials) Invalidate() {
+	o.source.Invalidate()
+}
+
+// authInterceptor authenticates the request, and retries once if server responds 401 and credentials are cached.
+func (hc *HTTPClient) authInterceptor(req *http.Request, next Invoker) (*http.Response, error) {
+	if err := hc.auth.Authenticate(req); err != nil {
+		return nil, err
+	}
+	resp, err := next(req)
+	if err != nil || resp.StatusCode != http.StatusUnauthorized {
+		return resp, err
+	}
+	refreshable, ok := hc.auth.(invalidator)
+	if !ok {
+		return resp, nil
+	}
+	refreshable.Invalidate()
+	retryReq := req.Clone(req.Context())
+	if req.Body != nil && req.Body != http.NoBody {
+		if req.GetBody == nil {
+			return resp, nil
+		}
+		body, err := req.GetBody()
+		if err != nil {
+			return resp, nil
+		}
+		retryReq.Body = body
+	}
+	drainBody(resp)
+	if err := hc.auth.Authenticate(retryReq); err != nil {
+		return nil, err
+	}
+	return next(retryReq)
+}
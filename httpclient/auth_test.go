@@ -0,0 +1,101 @@
+package httpclient
+
+import (
+	"context"
+	"fmt"
+	"github.com/uddmorningsun/go-livingkit/oauth2/clientcredentials"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+)
+
+// newOAuth2Server issues `token-<N>` for the N-th token request, and the resource accepts tokens accepted by valid.
+func newOAuth2Server(t *testing.T, valid func(token string) bool) (*HTTPClient, *int32, *int32) {
+	t.Helper()
+	var tokens, resources int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		if req.URL.Path == "/token" {
+			w.Header().Set("Content-Type", "application/json")
+			fmt.Fprintf(w, `{"access_token": "token-%d", "token_type": "bearer", "expires_in": 3600}`, atomic.AddInt32(&tokens, 1))
+			return
+		}
+		atomic.AddInt32(&resources, 1)
+		_, _ = io.Copy(io.Discard, req.Body)
+		if !valid(strings.TrimPrefix(req.Header.Get(headerAuthorization), "Bearer ")) {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	t.Cleanup(server.Close)
+	provider, err := OAuth2ClientCredentials(&clientcredentials.Config{TokenURL: server.URL + "/token", ClientID: "id"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	hc, err := NewHTTPClientWithOptions(WithAddress(server.URL), WithAuth(provider))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return hc, &tokens, &resources
+}
+
+func TestOAuth2ClientCredentialsConfig(t *testing.T) {
+	if _, err := OAuth2ClientCredentials(nil); err == nil {
+		t.Fatal("expected error of nil config")
+	}
+	if _, err := OAuth2ClientCredentials(&clientcredentials.Config{}); err == nil {
+		t.Fatal("expected error of empty token url")
+	}
+}
+
+func TestOAuth2RefreshesTokenOnUnauthorized(t *testing.T) {
+	hc, tokens, resources := newOAuth2Server(t, func(token string) bool { return token != "token-1" })
+	resp, err := hc.GetWithContext(context.Background(), "/resource", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	drainBody(resp)
+	if resp.StatusCode != http.StatusNoContent || atomic.LoadInt32(tokens) != 2 || atomic.LoadInt32(resources) != 2 {
+		t.Fatalf("expected retry with a refreshed token, got: %d after %d token and %d resource requests",
+			resp.StatusCode, atomic.LoadInt32(tokens), atomic.LoadInt32(resources))
+	}
+}
+
+func TestOAuth2RetriesUnauthorizedOnlyOnce(t *testing.T) {
+	hc, tokens, resources := newOAuth2Server(t, func(string) bool { return false })
+	resp, err := hc.GetWithContext(context.Background(), "/resource", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	drainBody(resp)
+	if resp.StatusCode != http.StatusUnauthorized || atomic.LoadInt32(tokens) != 2 || atomic.LoadInt32(resources) != 2 {
+		t.Fatalf("expected 401 after only 1 retry, got: %d after %d token and %d resource requests",
+			resp.StatusCode, atomic.LoadInt32(tokens), atomic.LoadInt32(resources))
+	}
+}
+
+func TestOAuth2DoesNotRetryNonRewindableBody(t *testing.T) {
+	hc, tokens, resources := newOAuth2Server(t, func(token string) bool { return token != "token-1" })
+	// io.MultiReader hides the concrete reader, so that the request has no GetBody.
+	body := io.MultiReader(strings.NewReader(`{"name": "a"}`))
+	resp, err := hc.DoRequestWithContext(context.Background(), http.MethodPost, "/resource", body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	drainBody(resp)
+	if resp.StatusCode != http.StatusUnauthorized || atomic.LoadInt32(resources) != 1 {
+		t.Fatalf("expected 401 without retry, got: %d after %d resource requests", resp.StatusCode, atomic.LoadInt32(resources))
+	}
+	// The cached token is invalidated anyway, so that the next request gets a new token.
+	resp, err = hc.DoRequestWithContext(context.Background(), http.MethodPost, "/resource", strings.NewReader("{}"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	drainBody(resp)
+	if resp.StatusCode != http.StatusNoContent || atomic.LoadInt32(tokens) != 2 {
+		t.Fatalf("expected the next request uses a new token, got: %d after %d token requests", resp.StatusCode, atomic.LoadInt32(tokens))
+	}
+}
@@ -0,0 +1,296 @@
+package httpclient
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/sirupsen/logrus"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// CircuitState is the state of a circuit breaker.
+type CircuitState int
+
+const (
+	// CircuitClosed lets all requests pass and counts failures.
+	CircuitClosed CircuitState = iota
+	// CircuitOpen rejects all requests until cool-down window is over.
+	CircuitOpen
+	// CircuitHalfOpen lets limited probe requests pass to decide whether to close or open again.
+	CircuitHalfOpen
+)
+
+func (s CircuitState) String() string {
+	switch s {
+	case CircuitClosed:
+		return "closed"
+	case CircuitOpen:
+		return "open"
+	case CircuitHalfOpen:
+		return "half-open"
+	}
+	return fmt.Sprintf("unknown(%d)", int(s))
+}
+
+// ErrCircuitOpen can be used with errors.Is to detect the request is rejected by circuit breaker.
+var ErrCircuitOpen = errors.New("circuit breaker is open")
+
+// CircuitOpenError is returned when circuit breaker of the host rejects the request.
+type CircuitOpenError struct {
+	Host  string
+	State CircuitState
+	// RetryAt is the time when breaker will turn to half-open and let probe requests pass.
+	RetryAt time.Time
+}
+
+func (e *CircuitOpenError) Error() string {
+	return fmt.Sprintf("circuit breaker of host: %s is %s, retry at: %s", e.Host, e.State, e.RetryAt.Format(time.RFC3339))
+}
+
+// Is makes errors.Is(err, ErrCircuitOpen) work.
+func (e *CircuitOpenError) Is(target error) bool {
+	return target == ErrCircuitOpen
+}
+
+// CircuitBreakerConfig configures the circuit breaker of each host, see WithCircuitBreaker.
+type CircuitBreakerConfig struct {
+	// ConsecutiveFailures trips the breaker after the given consecutive failures, zero disables it.
+	ConsecutiveFailures int
+	// FailureRatio trips the breaker once failures/requests in Window reaches it, zero disables it.
+	FailureRatio float64
+	// MinRequests is the minimum requests in Window before FailureRatio is checked.
+	MinRequests int
+	// Window is the interval to reset counts in closed state, zero means counts are never reset except state changes.
+	Window time.Duration
+	// CoolDown is how long the breaker keeps open before turning to half-open, default is 30s.
+	CoolDown time.Duration
+	// HalfOpenMaxRequests is the probe requests allowed in half-open state, all of them must succeed to close the
+	// breaker, default is 1.
+	HalfOpenMaxRequests int
+	// IsFailure reports whether the result is a failure, default is transport error or 5xx status code. *LimiterError
+	// and context.Canceled are never passed, they are counted as neither success nor failure.
+	IsFailure func(resp *http.Response, err error) bool
+	// OnStateChange is called when state of the host breaker changes, it's called without holding the breaker lock so it
+	// can call HTTPClient.CircuitState.
+	OnStateChange func(host string, from, to CircuitState)
+}
+
+// WithCircuitBreaker enables a circuit breaker per request host (HTTPClient.host if request URL has no host). Rejected
+// requests fail fast with *CircuitOpenError.
+func WithCircuitBreaker(cfg CircuitBreakerConfig) Option {
+	return func(hc *HTTPClient) error {
+		if cfg.ConsecutiveFailures < 0 || cfg.MinRequests < 0 || cfg.HalfOpenMaxRequests < 0 {
+			return fmt.Errorf("invalid circuit breaker config, thresholds should not be negative")
+		}
+		if cfg.FailureRatio < 0 || cfg.FailureRatio > 1 {
+			return fmt.Errorf("invalid circuit breaker config, 'FailureRatio' should be in [0, 1]")
+		}
+		if cfg.ConsecutiveFailures == 0 && cfg.FailureRatio == 0 {
+			return fmt.Errorf("invalid circuit breaker config, required 'ConsecutiveFailures' or 'FailureRatio'")
+		}
+		if cfg.CoolDown <= 0 {
+			cfg.CoolDown = 30 * time.Second
+		}
+		if cfg.HalfOpenMaxRequests == 0 {
+			cfg.HalfOpenMaxRequests = 1
+		}
+		if cfg.IsFailure == nil {
+			cfg.IsFailure = defaultIsFailure
+		}
+		hc.breakers = &circuitBreakers{cfg: cfg, breakers: make(map[string]*circuitBreaker)}
+		return nil
+	}
+}
+
+func defaultIsFailure(resp *http.Response, err error) bool {
+	return err != nil || resp.StatusCode >= http.StatusInternalServerError
+}
+
+type circuitBreakers struct {
+	cfg      CircuitBreakerConfig
+	mu       sync.Mutex
+	breakers map[string]*circuitBreaker
+}
+
+func (cbs *circuitBreakers) get(host string) *circuitBreaker {
+	cbs.mu.Lock()
+	defer cbs.mu.Unlock()
+	cb, ok := cbs.breakers[host]
+	if !ok {
+		cb = &circuitBreaker{cfg: &cbs.cfg, host: host, windowStart: time.Now()}
+		cbs.breakers[host] = cb
+	}
+	return cb
+}
+
+func (cbs *circuitBreakers) state(host string) CircuitState {
+	cb := cbs.get(host)
+	cb.mu.Lock()
+	defer cb.unlock()
+	cb.refresh(time.Now())
+	return cb.state
+}
+
+// do sends the request if breaker of the host allows it, and records the result.
+func (cbs *circuitBreakers) do(host string, req *http.Request, doFunc func(*http.Request) (*http.Response, error)) (*http.Response, error) {
+	cb := cbs.get(host)
+	generation, err := cb.allow()
+	if err != nil {
+		return nil, err
+	}
+	resp, err := doFunc(req)
+	// The request is not sent or its result is unknown, so it's neither success nor failure.
+	var limiterErr *LimiterError
+	if errors.As(err, &limiterErr) || errors.Is(err, context.Canceled) {
+		cb.release(generation)
+		return resp, err
+	}
+	cb.record(generation, cbs.cfg.IsFailure(resp, err))
+	return resp, err
+}
+
+type stateTransition struct {
+	from, to CircuitState
+}
+
+type circuitBreaker struct {
+	cfg  *CircuitBreakerConfig
+	host string
+
+	mu    sync.Mutex
+	state CircuitState
+	// generation changes when state changes or counting window is reset, result of the request admitted by an older
+	// generation is ignored, e.g.: a slow request admitted in closed state must not count as the probe of half-open.
+	generation           uint64
+	openedAt             time.Time
+	windowStart          time.Time
+	requests, failures   int
+	consecutiveFailures  int
+	halfOpenInflight     int
+	halfOpenSuccessCount int
+	// transitions are reported after the lock is released, so that OnStateChange can call HTTPClient.CircuitState.
+	transitions []stateTransition
+}
+
+// unlock releases the lock and then reports state transitions.
+func (cb *circuitBreaker) unlock() {
+	transitions := cb.transitions
+	cb.transitions = nil
+	cb.mu.Unlock()
+	for _, transition := range transitions {
+		logrus.Warningf("circuit breaker of host: %s changes state from %s to %s", cb.host, transition.from, transition.to)
+		if cb.cfg.OnStateChange != nil {
+			cb.cfg.OnStateChange(cb.host, transition.from, transition.to)
+		}
+	}
+}
+
+// refresh moves open state to half-open after cool-down and resets expired counting window, caller must hold the lock.
+func (cb *circuitBreaker) refresh(now time.Time) {
+	switch cb.state {
+	case CircuitOpen:
+		if now.Sub(cb.openedAt) >= cb.cfg.CoolDown {
+			cb.setState(CircuitHalfOpen, now)
+		}
+	case CircuitClosed:
+		if cb.cfg.Window > 0 && now.Sub(cb.windowStart) >= cb.cfg.Window {
+			cb.resetCounts(now)
+		}
+	}
+}
+
+// allow returns the generation which admits the request, it should be passed to record.
+func (cb *circuitBreaker) allow() (uint64, error) {
+	cb.mu.Lock()
+	defer cb.unlock()
+	now := time.Now()
+	cb.refresh(now)
+	switch cb.state {
+	case CircuitOpen:
+		return 0, &CircuitOpenError{Host: cb.host, State: cb.state, RetryAt: cb.openedAt.Add(cb.cfg.CoolDown)}
+	case CircuitHalfOpen:
+		if cb.halfOpenInflight+cb.halfOpenSuccessCount >= cb.cfg.HalfOpenMaxRequests {
+			return 0, &CircuitOpenError{Host: cb.host, State: cb.state, RetryAt: now}
+		}
+		cb.halfOpenInflight++
+	}
+	return cb.generation, nil
+}
+
+func (cb *circuitBreaker) record(generation uint64, failed bool) {
+	cb.mu.Lock()
+	defer cb.unlock()
+	if generation != cb.generation {
+		return
+	}
+	now := time.Now()
+	switch cb.state {
+	case CircuitHalfOpen:
+		cb.halfOpenInflight--
+		if failed {
+			cb.setState(CircuitOpen, now)
+			return
+		}
+		cb.halfOpenSuccessCount++
+		if cb.halfOpenSuccessCount >= cb.cfg.HalfOpenMaxRequests {
+			cb.setState(CircuitClosed, now)
+		}
+	case CircuitClosed:
+		cb.requests++
+		if !failed {
+			cb.consecutiveFailures = 0
+			return
+		}
+		cb.failures++
+		cb.consecutiveFailures++
+		if cb.shouldTrip() {
+			cb.setState(CircuitOpen, now)
+		}
+	}
+}
+
+// release gives back the probe slot of half-open state without a result, e.g.: the request is not sent or canceled.
+func (cb *circuitBreaker) release(generation uint64) {
+	cb.mu.Lock()
+	defer cb.unlock()
+	if generation == cb.generation && cb.state == CircuitHalfOpen {
+		cb.halfOpenInflight--
+	}
+}
+
+func (cb *circuitBreaker) shouldTrip() bool {
+	if cb.cfg.ConsecutiveFailures > 0 && cb.consecutiveFailures >= cb.cfg.ConsecutiveFailures {
+		return true
+	}
+	if cb.cfg.FailureRatio > 0 && cb.requests >= cb.cfg.MinRequests {
+		return float64(cb.failures)/float64(cb.requests) >= cb.cfg.FailureRatio
+	}
+	return false
+}
+
+func (cb *circuitBreaker) setState(state CircuitState, now time.Time) {
+	from := cb.state
+	cb.state = state
+	cb.resetCounts(now)
+	if state == CircuitOpen {
+		cb.openedAt = now
+	}
+	cb.transitions = append(cb.transitions, stateTransition{from: from, to: state})
+}
+
+func (cb *circuitBreaker) resetCounts(now time.Time) {
+	cb.generation++
+	cb.windowStart = now
+	cb.requests, cb.failures, cb.consecutiveFailures = 0, 0, 0
+	cb.halfOpenInflight, cb.halfOpenSuccessCount = 0, 0
+}
+
+// CircuitState returns the breaker state of the given host, false will be returned if WithCircuitBreaker is not enabled.
+func (hc *HTTPClient) CircuitState(host string) (CircuitState, bool) {
+	if hc.breakers == nil {
+		return CircuitClosed, false
+	}
+	return hc.breakers.state(host), true
+}
@@ -0,0 +1,234 @@
+package httpclient
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestCircuitBreakerTripsAndFailsFast(t *testing.T) {
+	var (
+		mu    sync.Mutex
+		calls int
+	)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		mu.Lock()
+		calls++
+		mu.Unlock()
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer server.Close()
+
+	hc, err := NewHTTPClientWithOptions(
+		WithAddress(server.URL), WithCircuitBreaker(CircuitBreakerConfig{ConsecutiveFailures: 2, CoolDown: time.Hour}),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 2; i++ {
+		resp, err := hc.GetWithContext(context.Background(), "/", nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		drainBody(resp)
+	}
+	_, err = hc.GetWithContext(context.Background(), "/", nil)
+	var openErr *CircuitOpenError
+	if !errors.Is(err, ErrCircuitOpen) || !errors.As(err, &openErr) {
+		t.Fatalf("expected open circuit error, got: %v", err)
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	if calls != 2 {
+		t.Fatalf("expected open breaker doesn't send request, got calls: %d", calls)
+	}
+	if state, _ := hc.CircuitState(openErr.Host); state != CircuitOpen {
+		t.Fatalf("expected open state, got: %s", state)
+	}
+}
+
+func TestCircuitBreakerOnStateChangeCanReadState(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.WriteHeader(http.StatusBadGateway)
+	}))
+	defer server.Close()
+
+	var (
+		hc     *HTTPClient
+		states []CircuitState
+	)
+	hc, err := NewHTTPClientWithOptions(WithAddress(server.URL), WithCircuitBreaker(CircuitBreakerConfig{
+		ConsecutiveFailures: 1,
+		OnStateChange: func(host string, _, _ CircuitState) {
+			state, _ := hc.CircuitState(host)
+			states = append(states, state)
+		},
+	}))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		resp, err := hc.GetWithContext(context.Background(), "/", nil)
+		if err == nil {
+			drainBody(resp)
+		}
+	}()
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("request is blocked by OnStateChange")
+	}
+	if len(states) != 1 || states[0] != CircuitOpen {
+		t.Fatalf("expected OnStateChange reads open state, got: %v", states)
+	}
+}
+
+func TestCircuitBreakerIgnoresStaleResult(t *testing.T) {
+	var (
+		received = make(chan string, 2)
+		release  = map[string]chan struct{}{"/slow": make(chan struct{}), "/probe": make(chan struct{})}
+	)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		if ch, ok := release[req.URL.Path]; ok {
+			received <- req.URL.Path
+			<-ch
+			w.WriteHeader(http.StatusOK)
+			return
+		}
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer server.Close()
+	var once sync.Map
+	releasePath := func(path string) {
+		if _, loaded := once.LoadOrStore(path, true); !loaded {
+			close(release[path])
+		}
+	}
+	// Blocked handlers must return before server.Close if the test fails.
+	defer releasePath("/slow")
+	defer releasePath("/probe")
+	host := strings.TrimPrefix(server.URL, "http://")
+
+	hc, err := NewHTTPClientWithOptions(
+		WithAddress(server.URL), WithCircuitBreaker(CircuitBreakerConfig{ConsecutiveFailures: 1, CoolDown: 20 * time.Millisecond}),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	get := func(path string) chan error {
+		result := make(chan error, 1)
+		go func() {
+			resp, err := hc.GetWithContext(context.Background(), path, nil)
+			if err == nil {
+				drainBody(resp)
+			}
+			result <- err
+		}()
+		return result
+	}
+
+	// Admitted in closed state, finishes after the breaker turns to half-open.
+	slow := get("/slow")
+	<-received
+	if err := <-get("/fail"); err != nil {
+		t.Fatal(err)
+	}
+	if state, _ := hc.CircuitState(host); state != CircuitOpen {
+		t.Fatalf("expected open state, got: %s", state)
+	}
+	time.Sleep(30 * time.Millisecond)
+	probe := get("/probe")
+	<-received
+
+	releasePath("/slow")
+	if err := <-slow; err != nil {
+		t.Fatal(err)
+	}
+	if state, _ := hc.CircuitState(host); state != CircuitHalfOpen {
+		t.Fatalf("expected stale success keeps half-open state, got: %s", state)
+	}
+	if _, err := hc.GetWithContext(context.Background(), "/fail", nil); !errors.Is(err, ErrCircuitOpen) {
+		t.Fatalf("expected probe slot is still taken, got: %v", err)
+	}
+
+	releasePath("/probe")
+	if err := <-probe; err != nil {
+		t.Fatal(err)
+	}
+	if state, _ := hc.CircuitState(host); state != CircuitClosed {
+		t.Fatalf("expected probe closes the breaker, got: %s", state)
+	}
+}
+
+func TestCircuitBreakerIgnoresCanceledRequest(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		if req.URL.Path == "/hang" {
+			<-req.Context().Done()
+			return
+		}
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer server.Close()
+	host := strings.TrimPrefix(server.URL, "http://")
+
+	send := func(t *testing.T, hc *HTTPClient, path string) {
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		if path == "/hang" {
+			time.AfterFunc(20*time.Millisecond, cancel)
+		}
+		resp, err := hc.GetWithContext(ctx, path, nil)
+		if path == "/hang" {
+			if !errors.Is(err, context.Canceled) {
+				t.Fatalf("expected canceled request, got: %v", err)
+			}
+			return
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		drainBody(resp)
+	}
+
+	t.Run("half-open", func(t *testing.T) {
+		hc, err := NewHTTPClientWithOptions(
+			WithAddress(server.URL), WithCircuitBreaker(CircuitBreakerConfig{ConsecutiveFailures: 1, CoolDown: 50 * time.Millisecond}),
+		)
+		if err != nil {
+			t.Fatal(err)
+		}
+		send(t, hc, "/fail")
+		time.Sleep(60 * time.Millisecond)
+		send(t, hc, "/hang")
+		if state, _ := hc.CircuitState(host); state != CircuitHalfOpen {
+			t.Fatalf("expected canceled probe keeps half-open state, got: %s", state)
+		}
+		// The probe slot is given back, so the next probe is allowed.
+		send(t, hc, "/fail")
+		if state, _ := hc.CircuitState(host); state != CircuitOpen {
+			t.Fatalf("expected failed probe opens the breaker, got: %s", state)
+		}
+	})
+	t.Run("closed", func(t *testing.T) {
+		hc, err := NewHTTPClientWithOptions(
+			WithAddress(server.URL), WithCircuitBreaker(CircuitBreakerConfig{ConsecutiveFailures: 2, CoolDown: time.Hour}),
+		)
+		if err != nil {
+			t.Fatal(err)
+		}
+		send(t, hc, "/fail")
+		send(t, hc, "/hang")
+		send(t, hc, "/fail")
+		if state, _ := hc.CircuitState(host); state != CircuitOpen {
+			t.Fatalf("expected canceled request doesn't reset consecutive failures, got: %s", state)
+		}
+	})
+}
@@ -0,0 +1,246 @@
+package httpclient
+
+import (
+	"context"
+	"fmt"
+	"github.com/uddmorningsun/go-livingkit"
+	"io"
+	"net/http"
+	urllib "net/url"
+	"time"
+)
+
+// FormFile is a file part of multipart/form-data body.
+type FormFile struct {
+	FieldName string
+	FileName  string
+	Content   io.Reader
+}
+
+// RequestBuilder builds and sends a request step by step, it's created by HTTPClient.Request. E.g.:
+//
+//	resp, err := hc.Request(http.MethodPost, "/v1/tenants/{tenant}/users").
+//		PathParam("tenant", tenant).
+//		Query("dry_run", "true").
+//		Header("X-Tenant", "default").
+//		JSON(user).
+//		Timeout(5 * time.Second).
+//		ExpectStatus(http.StatusCreated).
+//		Do()
+//
+// The first error of building steps will be returned by Do.
+type RequestBuilder struct {
+	hc             *HTTPClient
+	ctx            context.Context
+	method, path   string
+	pathParams     map[string]string
+	query          urllib.Values
+	header         http.Header
+	body           io.Reader
+	contentType    string
+	timeout        time.Duration
+	expectedStatus []int
+	opts           []RequestOption
+	err            error
+}
+
+// Request returns a RequestBuilder of the given method and path.
+func (hc *HTTPClient) Request(method, path string) *RequestBuilder {
+	return &RequestBuilder{
+		hc:         hc,
+		ctx:        context.Background(),
+		method:     method,
+		path:       path,
+		pathParams: map[string]string{},
+		query:      urllib.Values{},
+		header:     http.Header{},
+	}
+}
+
+// Context sets the request context.
+func (rb *RequestBuilder) Context(ctx context.Context) *RequestBuilder {
+	if ctx == nil {
+		rb.setError(fmt.Errorf("nil context"))
+		return rb
+	}
+	rb.ctx = ctx
+	return rb
+}
+
+// PathParam sets value of the `{key}` placeholder in path, value will be escaped, see ExpandPath.
+func (rb *RequestBuilder) PathParam(key, value string) *RequestBuilder {
+	rb.pathParams[key] = value
+	return rb
+}
+
+// Query adds the key value pair to URL query params.
+func (rb *RequestBuilder) Query(key, value string) *RequestBuilder {
+	rb.query.Add(key, value)
+	return rb
+}
+
+// Queries adds all of the given URL query params.
+func (rb *RequestBuilder) Queries(params urllib.Values) *RequestBuilder {
+	for key, values := range params {
+		for _, value := range values {
+			rb.query.Add(key, value)
+		}
+	}
+	return rb
+}
+
+// Header sets the request header.
+func (rb *RequestBuilder) Header(key, value string) *RequestBuilder {
+	rb.header.Set(key, value)
+	return rb
+}
+
+// Headers sets all of the given request headers.
+func (rb *RequestBuilder) Headers(header http.Header) *RequestBuilder {
+	for key, values := range header {
+		rb.header.Del(key)
+		for _, value := range values {
+			rb.header.Add(key, value)
+		}
+	}
+	return rb
+}
+
+// JSON sets the JSON encoding body.
+func (rb *RequestBuilder) JSON(json interface{}) *RequestBuilder {
+	body, ct, err := rb.hc.PrepareBody(json, nil)
+	if err != nil {
+		rb.setError(err)
+		return rb
+	}
+	return rb.Body(body, ct)
+}
+
+// Form sets the application/x-www-form-urlencoded body.
+func (rb *RequestBuilder) Form(data urllib.Values) *RequestBuilder {
+	body, ct, err := rb.hc.PrepareBody(nil, data)
+	if err != nil {
+		rb.setError(err)
+		return rb
+	}
+	return rb.Body(body, ct)
+}
+
+// Multipart sets the streaming multipart/form-data body with fields and files.
+func (rb *RequestBuilder) Multipart(fields urllib.Values, files ...FormFile) *RequestBuilder {
+	form := NewMultipartForm()
+	for key, values := range fields {
+		for _, value := range values {
+			form.Field(key, value)
+		}
+	}
+	for _, file := range files {
+		form.File(file.FieldName, file.FileName, file.Content)
+	}
+	return rb.MultipartForm(form)
+}
+
+// MultipartForm sets the streaming multipart/form-data body.
+func (rb *RequestBuilder) MultipartForm(form *MultipartForm) *RequestBuilder {
+	body, ct, err := rb.hc.PrepareMultipartBody(form)
+	if err != nil {
+		rb.setError(err)
+		return rb
+	}
+	return rb.Body(body, ct)
+}
+
+// Body sets the raw body with its content type, empty content type will keep the default one.
+func (rb *RequestBuilder) Body(body io.Reader, contentType string) *RequestBuilder {
+	rb.body = body
+	rb.contentType = contentType
+	return rb
+}
+
+// Timeout sets timeout of the whole request including reading response body, zero means no timeout.
+func (rb *RequestBuilder) Timeout(timeout time.Duration) *RequestBuilder {
+	rb.timeout = timeout
+	return rb
+}
+
+// ExpectStatus makes Do return error if response status code is not one of the given codes, the error is *HTTPError
+// parsed from the whole response body like HandleResponse.
+func (rb *RequestBuilder) ExpectStatus(codes ...int) *RequestBuilder {
+	rb.expectedStatus = append(rb.expectedStatus, codes...)
+	return rb
+}
+
+// Option appends customizable RequestOption which is applied after the builder steps.
+func (rb *RequestBuilder) Option(opts ...RequestOption) *RequestBuilder {
+	rb.opts = append(rb.opts, opts...)
+	return rb
+}
+
+func (rb *RequestBuilder) setError(err error) {
+	if rb.err == nil {
+		rb.err = err
+	}
+}
+
+// Do sends the built request.
+func (rb *RequestBuilder) Do() (*http.Response, error) {
+	if rb.err != nil {
+		return nil, fmt.Errorf("unable to build request, error: %s", rb.err)
+	}
+	path := rb.path
+	if len(rb.pathParams) > 0 {
+		expanded, err := ExpandPath(path, rb.pathParams)
+		if err != nil {
+			return nil, err
+		}
+		path = expanded
+	}
+	path, err := rb.hc.PreparePath(path, rb.query)
+	if err != nil {
+		return nil, err
+	}
+	opts := []RequestOption{func(request *http.Request) error {
+		for key, values := range rb.header {
+			request.Header[key] = values
+		}
+		if rb.contentType != "" {
+			request.Header.Set(livingkit.ContentType, rb.contentType)
+		}
+		return nil
+	}}
+	opts = append(opts, rb.opts...)
+
+	ctx, cancel := rb.ctx, context.CancelFunc(func() {})
+	if rb.timeout > 0 {
+		ctx, cancel = context.WithTimeout(ctx, rb.timeout)
+	}
+	resp, err := rb.hc.DoRequestWithContext(ctx, rb.method, path, rb.body, opts...)
+	if err != nil {
+		cancel()
+		return nil, err
+	}
+	resp.Body = &cancelOnCloseBody{ReadCloser: resp.Body, cancel: cancel}
+	if len(rb.expectedStatus) == 0 {
+		return resp, nil
+	}
+	for _, code := range rb.expectedStatus {
+		if resp.StatusCode == code {
+			return resp, nil
+		}
+	}
+	defer resp.Body.Close()
+	httpErr := readHTTPError(resp)
+	if httpErr.Err == nil && httpErr.Message == "" {
+		httpErr.Err = fmt.Errorf("unexpected status: %s, expected: %v", resp.Status, rb.expectedStatus)
+	}
+	return nil, httpErr
+}
+
+// Into sends the built request and decodes the response to entity, see HTTPClient.HandleResponse.
+func (rb *RequestBuilder) Into(entity interface{}) error {
+	resp, err := rb.Do()
+	if err != nil {
+		return err
+	}
+	return rb.hc.HandleResponse(resp, entity)
+}
@@ -0,0 +1,101 @@
+package httpclient
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/uddmorningsun/go-livingkit"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestRequestBuilder(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		body, _ := io.ReadAll(req.Body)
+		w.Header().Set(livingkit.ContentType, livingkit.ApplicationJSON)
+		w.WriteHeader(http.StatusCreated)
+		fmt.Fprintf(w, `{"method": %q, "path": %q, "query": %q, "tenant": %q, "contentType": %q, "body": %q}`,
+			req.Method, req.URL.EscapedPath(), req.URL.RawQuery, req.Header.Get("X-Tenant"),
+			req.Header.Get(livingkit.ContentType), body)
+	}))
+	defer server.Close()
+	hc, err := NewHTTPClientWithOptions(WithAddress(server.URL))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var echo map[string]string
+	err = hc.Request(http.MethodPost, "/v1/tenants/{tenant}/users").
+		PathParam("tenant", "a/b").
+		Query("dry_run", "true").
+		Header("X-Tenant", "default").
+		JSON(map[string]string{"name": "a"}).
+		ExpectStatus(http.StatusCreated).
+		Into(&echo)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := map[string]string{
+		"method":      http.MethodPost,
+		"path":        "/v1/tenants/a%2Fb/users",
+		"query":       "dry_run=true",
+		"tenant":      "default",
+		"contentType": livingkit.ApplicationJSON,
+		"body":        `{"name":"a"}`,
+	}
+	for key, value := range expected {
+		if echo[key] != value {
+			t.Fatalf("expected %s: %q, got: %q", key, value, echo[key])
+		}
+	}
+
+	if _, err := hc.Request(http.MethodGet, "/").Context(nil).Do(); err == nil || !strings.Contains(err.Error(), "nil context") {
+		t.Fatalf("expected error of building steps, got: %v", err)
+	}
+	if _, err := hc.Request(http.MethodGet, "/{missing}").PathParam("id", "1").Do(); err == nil {
+		t.Fatal("expected error of unknown path param")
+	}
+}
+
+func TestRequestBuilderExpectStatus(t *testing.T) {
+	long := strings.Repeat("x", maxErrorMessageBody*2)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		switch req.URL.Path {
+		case "/json":
+			w.Header().Set(livingkit.ContentType, livingkit.ApplicationJSON)
+			w.WriteHeader(http.StatusNotFound)
+			fmt.Fprintf(w, `{"success": false, "code": 1001, "message": %q}`, long)
+		case "/slow":
+			time.Sleep(200 * time.Millisecond)
+		default:
+			w.WriteHeader(http.StatusOK)
+		}
+	}))
+	defer server.Close()
+	hc, err := NewHTTPClientWithOptions(WithAddress(server.URL))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var httpErr *HTTPError
+	_, err = hc.Request(http.MethodGet, "/json").ExpectStatus(http.StatusOK).Do()
+	if !errors.As(err, &httpErr) || httpErr.Code != 1001 || httpErr.Message != long || httpErr.Err != nil {
+		t.Fatalf("expected HTTPError parsed from the whole JSON body, got: %v", err)
+	}
+	_, err = hc.Request(http.MethodGet, "/empty").ExpectStatus(http.StatusCreated, http.StatusAccepted).Do()
+	if !errors.As(err, &httpErr) || httpErr.Err == nil || !strings.Contains(err.Error(), "unexpected status: 200 OK") {
+		t.Fatalf("expected unexpected status error of empty body, got: %v", err)
+	}
+	resp, err := hc.Request(http.MethodGet, "/empty").ExpectStatus(http.StatusCreated, http.StatusOK).Do()
+	if err != nil {
+		t.Fatal(err)
+	}
+	drainBody(resp)
+	if _, err := hc.Request(http.MethodGet, "/slow").Timeout(20 * time.Millisecond).Do(); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("expected timeout, got: %v", err)
+	}
+}
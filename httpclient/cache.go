@@ -0,0 +1,372 @@
+package httpclient
+
+import (
+	"bytes"
+	"container/list"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"github.com/uddmorningsun/go-livingkit"
+	"io"
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// HeaderCacheStatus is set to every response of GET request if WithCache is enabled, see CacheOutcomeOf.
+	HeaderCacheStatus = "X-Livingkit-Cache"
+	// maxCacheBodySize is the maximum response body to be cached.
+	maxCacheBodySize = 10 << 20
+)
+
+// CacheOutcome reports how the response is served by cache.
+type CacheOutcome string
+
+const (
+	// CacheHit means the fresh cached response is served without request.
+	CacheHit CacheOutcome = "HIT"
+	// CacheMiss means the response is got from server.
+	CacheMiss CacheOutcome = "MISS"
+	// CacheRevalidated means the stale cached response is validated by server with 304 and served.
+	CacheRevalidated CacheOutcome = "REVALIDATED"
+)
+
+// CacheOutcomeOf returns the cache outcome of the response, empty outcome means the response is not handled by cache.
+func CacheOutcomeOf(resp *http.Response) CacheOutcome {
+	return CacheOutcome(resp.Header.Get(HeaderCacheStatus))
+}
+
+// CachedResponse is the response entry of CacheStorage.
+type CachedResponse struct {
+	StatusCode int
+	Status     string
+	Proto      string
+	Header     http.Header
+	Body       []byte
+	// StoredAt is the time when response is received or revalidated.
+	StoredAt time.Time
+	// VaryHeaders are request header values listed by `Vary` response header.
+	VaryHeaders map[string]string
+}
+
+// CacheStorage stores cached responses, implementations must be safe for concurrent use.
+type CacheStorage interface {
+	Get(key string) (*CachedResponse, bool)
+	Set(key string, resp *CachedResponse)
+	Delete(key string)
+}
+
+type lruEntry struct {
+	key  string
+	resp *CachedResponse
+}
+
+type lruCache struct {
+	mu       sync.Mutex
+	capacity int
+	entries  *list.List
+	elements map[string]*list.Element
+}
+
+// NewLRUCache returns an in-memory CacheStorage which evicts the least recently used response over capacity.
+func NewLRUCache(capacity int) CacheStorage {
+	if capacity <= 0 {
+		capacity = 128
+	}
+	return &lruCache{capacity: capacity, entries: list.New(), elements: make(map[string]*list.Element)}
+}
+
+func (c *lruCache) Get(key string) (*CachedResponse, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	element, ok := c.elements[key]
+	if !ok {
+		return nil, false
+	}
+	c.entries.MoveToFront(element)
+	return element.Value.(*lruEntry).resp, true
+}
+
+func (c *lruCache) Set(key string, resp *CachedResponse) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if element, ok := c.elements[key]; ok {
+		element.Value.(*lruEntry).resp = resp
+		c.entries.MoveToFront(element)
+		return
+	}
+	c.elements[key] = c.entries.PushFront(&lruEntry{key: key, resp: resp})
+	for c.entries.Len() > c.capacity {
+		oldest := c.entries.Back()
+		c.entries.Remove(oldest)
+		delete(c.elements, oldest.Value.(*lruEntry).key)
+	}
+}
+
+func (c *lruCache) Delete(key string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if element, ok := c.elements[key]; ok {
+		c.entries.Remove(element)
+		delete(c.elements, key)
+	}
+}
+
+// WithCache caches responses of GET requests as a private cache, it honors `Cache-Control` (max-age, no-cache, no-store),
+// `Expires`, and revalidates stale response with `ETag`/`If-None-Match` and `Last-Modified`/`If-Modified-Since`.
+// Requests carrying `Authorization` or `Cookie` are cached per credential. Only responses with known Content-Length up to
+// 10 MiB are stored. The outcome is reported by HeaderCacheStatus response header, see CacheOutcomeOf.
+func WithCache(storage CacheStorage) Option {
+	return func(hc *HTTPClient) error {
+		if storage == nil {
+			return fmt.Errorf("nil cache storage")
+		}
+		hc.cache = storage
+		return nil
+	}
+}
+
+func parseCacheControl(header http.Header) map[string]string {
+	directives := make(map[string]string)
+	for _, value := range header.Values("Cache-Control") {
+		for _, directive := range strings.Split(value, ",") {
+			directive = strings.TrimSpace(directive)
+			if directive == "" {
+				continue
+			}
+			name, arg := directive, ""
+			if index := strings.IndexByte(directive, '='); index >= 0 {
+				name, arg = directive[:index], strings.Trim(directive[index+1:], `"`)
+			}
+			directives[strings.ToLower(name)] = arg
+		}
+	}
+	return directives
+}
+
+// cacheable reports whether status code is cacheable by default, see: https://datatracker.ietf.org/doc/html/rfc7231#section-6.1
+func cacheable(statusCode int) bool {
+	switch statusCode {
+	case http.StatusOK, http.StatusNonAuthoritativeInfo, http.StatusMultipleChoices, http.StatusMovedPermanently,
+		http.StatusNotFound, http.StatusGone:
+		return true
+	}
+	return false
+}
+
+// freshnessLifetime returns max-age or Expires - Date of the response, false means no explicit freshness.
+func freshnessLifetime(cached *CachedResponse) (time.Duration, bool) {
+	directives := parseCacheControl(cached.Header)
+	if value, ok := directives["max-age"]; ok {
+		if seconds, err := strconv.Atoi(value); err == nil {
+			return time.Duration(seconds) * time.Second, true
+		}
+	}
+	if value := cached.Header.Get("Expires"); value != "" {
+		expires, err := http.ParseTime(value)
+		if err != nil {
+			// Invalid Expires means already expired.
+			return 0, true
+		}
+		date, err := http.ParseTime(cached.Header.Get("Date"))
+		if err != nil {
+			date = cached.StoredAt
+		}
+		return expires.Sub(date), true
+	}
+	return 0, false
+}
+
+func (cached *CachedResponse) fresh(now time.Time) bool {
+	if _, ok := parseCacheControl(cached.Header)["no-cache"]; ok {
+		return false
+	}
+	lifetime, ok := freshnessLifetime(cached)
+	if !ok {
+		return false
+	}
+	age := now.Sub(cached.StoredAt)
+	if seconds, err := strconv.Atoi(cached.Header.Get("Age")); err == nil {
+		age += time.Duration(seconds) * time.Second
+	}
+	return age < lifetime
+}
+
+func (cached *CachedResponse) matchVary(req *http.Request) bool {
+	for key, value := range cached.VaryHeaders {
+		if req.Header.Get(key) != value {
+			return false
+		}
+	}
+	return true
+}
+
+func (cached *CachedResponse) response(req *http.Request, outcome CacheOutcome) *http.Response {
+	header := cached.Header.Clone()
+	header.Set(HeaderCacheStatus, string(outcome))
+	return &http.Response{
+		Status:        cached.Status,
+		StatusCode:    cached.StatusCode,
+		Proto:         cached.Proto,
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        header,
+		Body:          io.NopCloser(bytes.NewReader(cached.Body)),
+		ContentLength: int64(len(cached.Body)),
+		Request:       req,
+	}
+}
+
+// credentialHeaders are request headers which identify the user, responses of different credentials never share entry.
+var credentialHeaders = []string{"Authorization", "Cookie"}
+
+// cacheKey returns method and URL of the request, with the hash of credential headers if the request carries any.
+func cacheKey(req *http.Request) string {
+	key := fmt.Sprintf("%s %s", req.Method, req.URL.String())
+	hash, hasCredential := sha256.New(), false
+	for _, name := range credentialHeaders {
+		for _, value := range req.Header.Values(name) {
+			hasCredential = true
+			fmt.Fprintf(hash, "%s: %s\n", name, value)
+		}
+	}
+	if hasCredential {
+		key += " " + hex.EncodeToString(hash.Sum(nil))
+	}
+	return key
+}
+
+// cacheInterceptor serves the request from cache storage, and stores the cacheable response.
+func (hc *HTTPClient) cacheInterceptor(req *http.Request, next Invoker) (*http.Response, error) {
+	// Partial responses are not cached.
+	if req.Method != http.MethodGet || req.Header.Get("Range") != "" {
+		return next(req)
+	}
+	key := cacheKey(req)
+	requestDirectives := parseCacheControl(req.Header)
+	if _, ok := requestDirectives["no-store"]; ok {
+		hc.cache.Delete(key)
+		return next(req)
+	}
+
+	cached, ok := hc.cache.Get(key)
+	if ok && !cached.matchVary(req) {
+		cached, ok = nil, false
+	}
+	if ok {
+		_, noCache := requestDirectives["no-cache"]
+		if !noCache && cached.fresh(time.Now()) {
+			return cached.response(req, CacheHit), nil
+		}
+		req = req.Clone(req.Context())
+		if etag := cached.Header.Get("ETag"); etag != "" {
+			req.Header.Set("If-None-Match", etag)
+		}
+		if lastModified := cached.Header.Get("Last-Modified"); lastModified != "" {
+			req.Header.Set("If-Modified-Since", lastModified)
+		}
+	}
+
+	resp, err := next(req)
+	if err != nil {
+		return nil, err
+	}
+	if ok && resp.StatusCode == http.StatusNotModified {
+		drainBody(resp)
+		revalidated := *cached
+		revalidated.Header = cached.Header.Clone()
+		updateStoredHeader(revalidated.Header, resp.Header)
+		revalidated.StoredAt = time.Now()
+		hc.cache.Set(key, &revalidated)
+		return revalidated.response(req, CacheRevalidated), nil
+	}
+	resp.Header.Set(HeaderCacheStatus, string(CacheMiss))
+	hc.storeResponse(key, req, resp)
+	return resp, nil
+}
+
+// notUpdatedHeaders are not updated by 304 response, since they describe the 304 response itself rather than the stored
+// one, see: https://datatracker.ietf.org/doc/html/rfc9111#section-3.2
+var notUpdatedHeaders = map[string]bool{
+	"Connection":        true,
+	"Proxy-Connection":  true,
+	"Keep-Alive":        true,
+	"Te":                true,
+	"Transfer-Encoding": true,
+	"Upgrade":           true,
+	"Content-Length":    true,
+	"Content-Encoding":  true,
+	"Content-Range":     true,
+	HeaderCacheStatus:   true,
+}
+
+// updateStoredHeader updates the stored header with header of 304 response, see:
+// https://datatracker.ietf.org/doc/html/rfc9111#section-4.3.4
+func updateStoredHeader(stored, header http.Header) {
+	excluded := make(map[string]bool)
+	for _, value := range header.Values("Connection") {
+		for _, name := range strings.Split(value, ",") {
+			excluded[http.CanonicalHeaderKey(strings.TrimSpace(name))] = true
+		}
+	}
+	for key, values := range header {
+		key = http.CanonicalHeaderKey(key)
+		if notUpdatedHeaders[key] || excluded[key] {
+			continue
+		}
+		stored[key] = values
+	}
+}
+
+// storeResponse stores the response if it's cacheable, response body is buffered and put back. Response with unknown
+// or oversize Content-Length, or streaming media type, is not stored since buffering it would block the caller.
+func (hc *HTTPClient) storeResponse(key string, req *http.Request, resp *http.Response) {
+	directives := parseCacheControl(resp.Header)
+	if _, ok := directives["no-store"]; ok || !cacheable(resp.StatusCode) {
+		hc.cache.Delete(key)
+		return
+	}
+	if resp.ContentLength < 0 || resp.ContentLength > maxCacheBodySize {
+		return
+	}
+	if mediaType, _, _ := mime.ParseMediaType(resp.Header.Get(livingkit.ContentType)); streamingMediaTypes[mediaType] {
+		return
+	}
+	_, hasLifetime := freshnessLifetime(&CachedResponse{Header: resp.Header, StoredAt: time.Now()})
+	if !hasLifetime && resp.Header.Get("ETag") == "" && resp.Header.Get("Last-Modified") == "" {
+		return
+	}
+	varyHeaders := make(map[string]string)
+	for _, value := range resp.Header.Values("Vary") {
+		for _, name := range strings.Split(value, ",") {
+			name = strings.TrimSpace(name)
+			if name == "*" {
+				return
+			}
+			if name != "" {
+				varyHeaders[http.CanonicalHeaderKey(name)] = req.Header.Get(name)
+			}
+		}
+	}
+
+	payload, err := io.ReadAll(io.LimitReader(resp.Body, resp.ContentLength))
+	resp.Body = &multiReadCloser{Reader: io.MultiReader(bytes.NewReader(payload), resp.Body), Closer: resp.Body}
+	if err != nil || int64(len(payload)) != resp.ContentLength {
+		return
+	}
+	header := resp.Header.Clone()
+	header.Del(HeaderCacheStatus)
+	hc.cache.Set(key, &CachedResponse{
+		StatusCode:  resp.StatusCode,
+		Status:      resp.Status,
+		Proto:       resp.Proto,
+		Header:      header,
+		Body:        payload,
+		StoredAt:    time.Now(),
+		VaryHeaders: varyHeaders,
+	})
+}
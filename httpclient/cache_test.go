@@ -0,0 +1,186 @@
+package httpclient
+
+import (
+	"context"
+	"fmt"
+	"github.com/uddmorningsun/go-livingkit"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+)
+
+func getCached(t *testing.T, hc *HTTPClient, path string, header map[string]string) (CacheOutcome, string) {
+	t.Helper()
+	resp, err := hc.GetWithContext(context.Background(), path, nil, func(req *http.Request) error {
+		for key, value := range header {
+			req.Header.Set(key, value)
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	payload, err := io.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return CacheOutcomeOf(resp), string(payload)
+}
+
+func TestCacheFreshAndRevalidated(t *testing.T) {
+	var calls int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		atomic.AddInt32(&calls, 1)
+		if req.Header.Get("If-None-Match") == `"v1"` {
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		w.Header().Set("ETag", `"v1"`)
+		if req.URL.Path == "/fresh" {
+			w.Header().Set("Cache-Control", "max-age=60")
+		} else {
+			w.Header().Set("Cache-Control", "no-cache")
+		}
+		_, _ = w.Write([]byte(req.URL.Path))
+	}))
+	defer server.Close()
+
+	hc, err := NewHTTPClientWithOptions(WithAddress(server.URL), WithCache(NewLRUCache(0)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	cases := []struct {
+		path     string
+		expected []CacheOutcome
+		calls    int32
+	}{
+		{path: "/fresh", expected: []CacheOutcome{CacheMiss, CacheHit}, calls: 1},
+		{path: "/stale", expected: []CacheOutcome{CacheMiss, CacheRevalidated}, calls: 2},
+	}
+	for _, c := range cases {
+		atomic.StoreInt32(&calls, 0)
+		for i, expected := range c.expected {
+			outcome, body := getCached(t, hc, c.path, nil)
+			if outcome != expected || body != c.path {
+				t.Fatalf("expected %s of %s request %d, got: %s %q", expected, c.path, i, outcome, body)
+			}
+		}
+		if actual := atomic.LoadInt32(&calls); actual != c.calls {
+			t.Fatalf("expected %d calls of %s, got: %d", c.calls, c.path, actual)
+		}
+	}
+}
+
+func TestCacheKeyedByCredential(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		w.Header().Set("Cache-Control", "max-age=60")
+		_, _ = w.Write([]byte(req.Header.Get("Authorization") + req.Header.Get("Cookie")))
+	}))
+	defer server.Close()
+
+	hc, err := NewHTTPClientWithOptions(WithAddress(server.URL), WithCache(NewLRUCache(0)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	users := []map[string]string{
+		{"Authorization": "Bearer alice"},
+		{"Authorization": "Bearer bob"},
+		{"Cookie": "session=alice"},
+		{},
+	}
+	for _, header := range users {
+		expected := header["Authorization"] + header["Cookie"]
+		if outcome, body := getCached(t, hc, "/me", header); outcome != CacheMiss || body != expected {
+			t.Fatalf("expected MISS %q with %v, got: %s %q", expected, header, outcome, body)
+		}
+	}
+	for _, header := range users {
+		expected := header["Authorization"] + header["Cookie"]
+		if outcome, body := getCached(t, hc, "/me", header); outcome != CacheHit || body != expected {
+			t.Fatalf("expected HIT %q with %v, got: %s %q", expected, header, outcome, body)
+		}
+	}
+}
+
+func TestCacheSkipsUnboundedBody(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		w.Header().Set("Cache-Control", "max-age=60")
+		switch req.URL.Path {
+		case "/chunked":
+			_, _ = w.Write([]byte("chunk"))
+			w.(http.Flusher).Flush()
+		case "/stream":
+			w.Header().Set(livingkit.ContentType, livingkit.TextEventStream)
+			w.Header().Set("Content-Length", "5")
+			_, _ = w.Write([]byte("event"))
+		case "/oversize":
+			w.Header().Set("Content-Length", fmt.Sprint(maxCacheBodySize+1))
+			_, _ = w.Write(make([]byte, maxCacheBodySize+1))
+		}
+	}))
+	defer server.Close()
+
+	hc, err := NewHTTPClientWithOptions(WithAddress(server.URL), WithCache(NewLRUCache(0)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, path := range []string{"/chunked", "/stream", "/oversize"} {
+		for i := 0; i < 2; i++ {
+			if outcome, _ := getCached(t, hc, path, nil); outcome != CacheMiss {
+				t.Fatalf("expected %s not stored, got: %s", path, outcome)
+			}
+		}
+	}
+}
+
+// roundTripFunc is an adapter to allow the use of ordinary functions as http.RoundTripper.
+type roundTripFunc func(req *http.Request) (*http.Response, error)
+
+func (f roundTripFunc) RoundTrip(req *http.Request) (*http.Response, error) {
+	return f(req)
+}
+
+func TestCacheRevalidatedHeaders(t *testing.T) {
+	var calls int32
+	transport := roundTripFunc(func(req *http.Request) (*http.Response, error) {
+		version := atomic.AddInt32(&calls, 1)
+		header := http.Header{"Cache-Control": {"no-cache"}, "X-Version": {fmt.Sprint(version)}}
+		if req.Header.Get("If-None-Match") != `"v1"` {
+			header.Set("ETag", `"v1"`)
+			header.Set("Content-Length", "5")
+			return &http.Response{StatusCode: http.StatusOK, Header: header, Body: io.NopCloser(strings.NewReader("hello")),
+				ContentLength: 5, Request: req}, nil
+		}
+		header.Set("Content-Length", "0")
+		header.Set("Connection", "close, X-Hop")
+		header.Set("X-Hop", "1")
+		header.Set("Transfer-Encoding", "chunked")
+		return &http.Response{StatusCode: http.StatusNotModified, Header: header, Body: http.NoBody, Request: req}, nil
+	})
+	hc, err := NewHTTPClientWithOptions(WithAddress("http://api"), WithTransport(transport), WithCache(NewLRUCache(0)))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for i := 1; i <= 3; i++ {
+		resp, err := hc.GetWithContext(context.Background(), "/", nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		body, _ := io.ReadAll(resp.Body)
+		resp.Body.Close()
+		if string(body) != "hello" || resp.Header.Get("X-Version") != fmt.Sprint(i) {
+			t.Fatalf("expected stored body with updated header of request %d, got: %q, version: %s", i, body,
+				resp.Header.Get("X-Version"))
+		}
+		for key, value := range map[string]string{"Content-Length": "5", "Connection": "", "X-Hop": "", "Transfer-Encoding": ""} {
+			if actual := resp.Header.Get(key); actual != value {
+				t.Fatalf("expected header %s: %q of request %d, got: %q", key, value, i, actual)
+			}
+		}
+	}
+}
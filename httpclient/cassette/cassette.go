@@ -0,0 +1,377 @@
+// Package cassette provides a record/replay http.RoundTripper for testing code built on httpclient.HTTPClient, e.g.:
+//
+//	recorder, err := cassette.New(cassette.Config{Path: "testdata/users.json", Mode: cassette.ModeReplay})
+//	if err != nil {
+//		t.Fatal(err)
+//	}
+//	defer recorder.Stop()
+//	hc, err := httpclient.NewHTTPClientWithOptions(httpclient.WithAddress(address), httpclient.WithTransport(recorder))
+package cassette
+
+import (
+	"bytes"
+	"encoding/base64"
+	jsonlib "encoding/json"
+	"errors"
+	"fmt"
+	"github.com/uddmorningsun/go-livingkit"
+	"github.com/uddmorningsun/go-livingkit/httpclient"
+	"io"
+	"net/http"
+	urllib "net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"unicode/utf8"
+)
+
+const (
+	formatVersion  = 1
+	encodingBase64 = "base64"
+)
+
+// ErrInteractionNotFound is returned by Recorder.RoundTrip if no recorded interaction matches the request in replay mode.
+var ErrInteractionNotFound = errors.New("cassette interaction not found")
+
+// Mode is the working mode of Recorder.
+type Mode int
+
+const (
+	// ModeReplay replays recorded interactions without network, the request which matches none fails.
+	ModeReplay Mode = iota
+	// ModeRecord sends every request with the real transport and records it, existing interactions are dropped.
+	ModeRecord
+	// ModeReplayOrRecord replays matched interactions, and records the others.
+	ModeReplayOrRecord
+)
+
+// Request is the recorded request, it's redacted before recording and matching.
+type Request struct {
+	Method       string      `json:"method"`
+	URL          string      `json:"url"`
+	Header       http.Header `json:"header,omitempty"`
+	Body         string      `json:"body,omitempty"`
+	BodyEncoding string      `json:"bodyEncoding,omitempty"`
+}
+
+// Response is the recorded response.
+type Response struct {
+	StatusCode   int         `json:"statusCode"`
+	Status       string      `json:"status"`
+	Header       http.Header `json:"header,omitempty"`
+	Body         string      `json:"body,omitempty"`
+	BodyEncoding string      `json:"bodyEncoding,omitempty"`
+}
+
+// Interaction is a recorded request and response exchange.
+type Interaction struct {
+	Request  Request  `json:"request"`
+	Response Response `json:"response"`
+}
+
+type cassetteFile struct {
+	Version      int            `json:"version"`
+	Interactions []*Interaction `json:"interactions"`
+}
+
+// Matcher reports whether the live request matches the recorded request.
+type Matcher func(live, recorded *Request) bool
+
+// MatchMethod matches request method.
+func MatchMethod(live, recorded *Request) bool {
+	return live.Method == recorded.Method
+}
+
+// MatchURL matches the whole URL, query params order doesn't matter.
+func MatchURL(live, recorded *Request) bool {
+	liveURL, err := urllib.Parse(live.URL)
+	if err != nil {
+		return false
+	}
+	recordedURL, err := urllib.Parse(recorded.URL)
+	if err != nil {
+		return false
+	}
+	liveQuery, recordedQuery := liveURL.Query(), recordedURL.Query()
+	liveURL.RawQuery, recordedURL.RawQuery = "", ""
+	return liveURL.String() == recordedURL.String() && liveQuery.Encode() == recordedQuery.Encode()
+}
+
+// MatchPath matches URL path and query params, scheme and host are ignored, e.g.: the recorded server listens on a
+// random port.
+func MatchPath(live, recorded *Request) bool {
+	liveURL, err := urllib.Parse(live.URL)
+	if err != nil {
+		return false
+	}
+	recordedURL, err := urllib.Parse(recorded.URL)
+	if err != nil {
+		return false
+	}
+	return liveURL.EscapedPath() == recordedURL.EscapedPath() && liveURL.Query().Encode() == recordedURL.Query().Encode()
+}
+
+// MatchBody matches request body, JSON bodies are compared semantically.
+func MatchBody(live, recorded *Request) bool {
+	if live.Body == recorded.Body && live.BodyEncoding == recorded.BodyEncoding {
+		return true
+	}
+	var liveValue, recordedValue interface{}
+	if jsonlib.Unmarshal([]byte(live.Body), &liveValue) != nil || jsonlib.Unmarshal([]byte(recorded.Body), &recordedValue) != nil {
+		return false
+	}
+	liveJSON, _ := jsonlib.Marshal(liveValue)
+	recordedJSON, _ := jsonlib.Marshal(recordedValue)
+	return bytes.Equal(liveJSON, recordedJSON)
+}
+
+// MatchHeaders matches values of the given headers.
+func MatchHeaders(names ...string) Matcher {
+	return func(live, recorded *Request) bool {
+		for _, name := range names {
+			if strings.Join(live.Header.Values(name), ",") != strings.Join(recorded.Header.Values(name), ",") {
+				return false
+			}
+		}
+		return true
+	}
+}
+
+// MatchAll matches if all of the matchers match.
+func MatchAll(matchers ...Matcher) Matcher {
+	return func(live, recorded *Request) bool {
+		for _, matcher := range matchers {
+			if !matcher(live, recorded) {
+				return false
+			}
+		}
+		return true
+	}
+}
+
+// DefaultMatcher matches request method and URL.
+var DefaultMatcher = MatchAll(MatchMethod, MatchURL)
+
+// Config configures a Recorder.
+type Config struct {
+	// Path is the cassette file, e.g.: `testdata/cassettes/users.json`.
+	Path string
+	Mode Mode
+	// Matcher matches live request with recorded ones, default is DefaultMatcher.
+	Matcher Matcher
+	// RedactHeaders are redacted in addition to httpclient.DefaultRedactHeaders, case-insensitive.
+	RedactHeaders []string
+	// RedactFields are redacted in addition to httpclient.DefaultRedactFields in URL query params, JSON and form body,
+	// case-insensitive.
+	RedactFields []string
+	// Redact is called with every interaction before recording, e.g.: replace account IDs of response body.
+	Redact func(interaction *Interaction)
+	// Transport sends requests in record mode, default is http.DefaultTransport.
+	Transport http.RoundTripper
+}
+
+// Recorder is a record/replay http.RoundTripper, it's safe for concurrent use. Matched interactions are replayed once
+// in recorded order, so that repeated requests get their responses in sequence.
+type Recorder struct {
+	cfg      Config
+	redactor *httpclient.Redactor
+
+	mu           sync.Mutex
+	interactions []*Interaction
+	used         []bool
+	changed      bool
+}
+
+// New loads the cassette file, the file is required in ModeReplay.
+func New(cfg Config) (*Recorder, error) {
+	if cfg.Path == "" {
+		return nil, fmt.Errorf("required cassette path")
+	}
+	if cfg.Matcher == nil {
+		cfg.Matcher = DefaultMatcher
+	}
+	if cfg.Transport == nil {
+		cfg.Transport = http.DefaultTransport
+	}
+	r := &Recorder{cfg: cfg, redactor: httpclient.NewRedactor(cfg.RedactHeaders, cfg.RedactFields)}
+	if cfg.Mode == ModeRecord {
+		return r, nil
+	}
+
+	content, err := os.ReadFile(cfg.Path)
+	switch {
+	case os.IsNotExist(err) && cfg.Mode == ModeReplayOrRecord:
+		return r, nil
+	case err != nil:
+		return nil, fmt.Errorf("unable to read cassette: %s, error: %s", cfg.Path, err)
+	}
+	var file cassetteFile
+	if err := jsonlib.Unmarshal(content, &file); err != nil {
+		return nil, fmt.Errorf("unable to unmarshal cassette: %s, error: %s", cfg.Path, err)
+	}
+	if file.Version != formatVersion {
+		return nil, fmt.Errorf("unsupported cassette version: %d", file.Version)
+	}
+	r.interactions, r.used = file.Interactions, make([]bool, len(file.Interactions))
+	return r, nil
+}
+
+// RoundTrip replays the matched interaction, or records the real exchange according to Mode.
+func (r *Recorder) RoundTrip(req *http.Request) (*http.Response, error) {
+	var body []byte
+	if req.Body != nil && req.Body != http.NoBody {
+		payload, err := io.ReadAll(req.Body)
+		req.Body.Close()
+		if err != nil {
+			return nil, fmt.Errorf("unable to read request body, error: %s", err)
+		}
+		body = payload
+	}
+	live := r.request(req, body)
+
+	if r.cfg.Mode != ModeRecord {
+		if interaction, ok := r.match(live); ok {
+			return interaction.Response.response(req)
+		}
+		if r.cfg.Mode == ModeReplay {
+			return nil, fmt.Errorf("%w: %s %s", ErrInteractionNotFound, live.Method, live.URL)
+		}
+	}
+
+	outReq := req.Clone(req.Context())
+	if body != nil {
+		outReq.Body = io.NopCloser(bytes.NewReader(body))
+	}
+	resp, err := r.cfg.Transport.RoundTrip(outReq)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	payload, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("unable to read response body, error: %s", err)
+	}
+	interaction := &Interaction{Request: *live, Response: r.response(resp, payload)}
+	if r.cfg.Redact != nil {
+		r.cfg.Redact(interaction)
+	}
+	r.mu.Lock()
+	r.interactions, r.used = append(r.interactions, interaction), append(r.used, true)
+	r.changed = true
+	r.mu.Unlock()
+
+	resp.Body = io.NopCloser(bytes.NewReader(payload))
+	resp.ContentLength = int64(len(payload))
+	return resp, nil
+}
+
+// Stop saves the recorded interactions to the cassette file if there are new ones.
+func (r *Recorder) Stop() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if !r.changed && r.cfg.Mode != ModeRecord {
+		return nil
+	}
+	content, err := jsonlib.MarshalIndent(cassetteFile{Version: formatVersion, Interactions: r.interactions}, "", "  ")
+	if err != nil {
+		return fmt.Errorf("unable to marshal cassette, error: %s", err)
+	}
+	if err := os.MkdirAll(filepath.Dir(r.cfg.Path), 0o755); err != nil {
+		return fmt.Errorf("unable to create cassette directory, error: %s", err)
+	}
+	if err := os.WriteFile(r.cfg.Path, append(content, '\n'), 0o644); err != nil {
+		return fmt.Errorf("unable to write cassette: %s, error: %s", r.cfg.Path, err)
+	}
+	r.changed = false
+	return nil
+}
+
+// Unused returns the recorded interactions which are not replayed, e.g.: assert that all of them are consumed.
+func (r *Recorder) Unused() []*Interaction {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	var unused []*Interaction
+	for i, interaction := range r.interactions {
+		if !r.used[i] {
+			unused = append(unused, interaction)
+		}
+	}
+	return unused
+}
+
+func (r *Recorder) match(live *Request) (*Interaction, bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for i, interaction := range r.interactions {
+		if !r.used[i] && r.cfg.Matcher(live, &interaction.Request) {
+			r.used[i] = true
+			return interaction, true
+		}
+	}
+	return nil, false
+}
+
+func (r *Recorder) request(req *http.Request, body []byte) *Request {
+	content, encoding := encodeBody(r.redactor.Body(req.Header.Get(livingkit.ContentType), body))
+	return &Request{
+		Method:       req.Method,
+		URL:          r.redactor.URL(req.URL),
+		Header:       r.redactor.Header(req.Header),
+		Body:         content,
+		BodyEncoding: encoding,
+	}
+}
+
+func (r *Recorder) response(resp *http.Response, body []byte) Response {
+	content, encoding := encodeBody(r.redactor.Body(resp.Header.Get(livingkit.ContentType), body))
+	return Response{
+		StatusCode:   resp.StatusCode,
+		Status:       resp.Status,
+		Header:       r.redactor.Header(resp.Header),
+		Body:         content,
+		BodyEncoding: encoding,
+	}
+}
+
+// encodeBody keeps UTF-8 body readable in cassette file, and encodes binary body with base64.
+func encodeBody(body []byte) (string, string) {
+	if utf8.Valid(body) {
+		return string(body), ""
+	}
+	return base64.StdEncoding.EncodeToString(body), encodingBase64
+}
+
+func decodeBody(body, encoding string) ([]byte, error) {
+	if encoding == encodingBase64 {
+		return base64.StdEncoding.DecodeString(body)
+	}
+	return []byte(body), nil
+}
+
+func (recorded *Response) response(req *http.Request) (*http.Response, error) {
+	body, err := decodeBody(recorded.Body, recorded.BodyEncoding)
+	if err != nil {
+		return nil, fmt.Errorf("unable to decode recorded body, error: %s", err)
+	}
+	header := recorded.Header.Clone()
+	if header == nil {
+		header = make(http.Header)
+	}
+	// Body length may be changed by redaction.
+	if header.Get("Content-Length") != "" {
+		header.Set("Content-Length", strconv.Itoa(len(body)))
+	}
+	return &http.Response{
+		Status:        recorded.Status,
+		StatusCode:    recorded.StatusCode,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        header,
+		Body:          io.NopCloser(bytes.NewReader(body)),
+		ContentLength: int64(len(body)),
+		Request:       req,
+	}, nil
+}
@@ -0,0 +1,173 @@
+package cassette
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"github.com/uddmorningsun/go-livingkit"
+	"github.com/uddmorningsun/go-livingkit/httpclient"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	urllib "net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+)
+
+var binaryContent = []byte{0xff, 0xfe, 0x00, 0x01}
+
+// newCassetteServer responds a JSON body with secrets for POST /users, and binary body for GET /binary.
+func newCassetteServer(t *testing.T) (*httptest.Server, *int32) {
+	t.Helper()
+	var calls int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		atomic.AddInt32(&calls, 1)
+		_, _ = io.Copy(io.Discard, req.Body)
+		if req.URL.Path == "/binary" {
+			w.Header().Set(livingkit.ContentType, "application/octet-stream")
+			_, _ = w.Write(binaryContent)
+			return
+		}
+		http.SetCookie(w, &http.Cookie{Name: "session", Value: "cookie-value"})
+		w.Header().Set(livingkit.ContentType, livingkit.ApplicationJSON)
+		w.WriteHeader(http.StatusCreated)
+		fmt.Fprint(w, `{"id": 1, "access_token": "server-token"}`)
+	}))
+	t.Cleanup(server.Close)
+	return server, &calls
+}
+
+func newCassetteClient(t *testing.T, address string, recorder *Recorder) *httpclient.HTTPClient {
+	t.Helper()
+	hc, err := httpclient.NewHTTPClientWithOptions(
+		httpclient.WithAddress(address),
+		httpclient.WithTransport(recorder),
+		httpclient.WithAuth(httpclient.BearerToken("bearer-token")),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return hc
+}
+
+// exchange sends the requests which are recorded and replayed.
+func exchange(t *testing.T, hc *httpclient.HTTPClient) (string, []byte) {
+	t.Helper()
+	resp, err := hc.PostWithContext(context.Background(), "/users", map[string]string{"name": "a", "password": "p4ssw0rd"},
+		nil, func(req *http.Request) error {
+			req.URL.RawQuery = urllib.Values{"api_key": {"query-key"}, "page": {"1"}}.Encode()
+			req.Header.Set("X-Tenant-Secret", "tenant-secret")
+			return nil
+		})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var user map[string]interface{}
+	if err := hc.HandleResponse(resp, &user); err != nil {
+		t.Fatal(err)
+	}
+	resp, err = hc.GetWithContext(context.Background(), "/binary", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	binary, err := io.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return fmt.Sprint(user), binary
+}
+
+func TestRecordAndReplay(t *testing.T) {
+	server, calls := newCassetteServer(t)
+	cfg := Config{
+		Path:          filepath.Join(t.TempDir(), "cassettes", "users.json"),
+		Mode:          ModeRecord,
+		RedactHeaders: []string{"X-Tenant-Secret"},
+		RedactFields:  []string{"api_key"},
+	}
+	recorder, err := New(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	recordedUser, recordedBinary := exchange(t, newCassetteClient(t, server.URL, recorder))
+	if err := recorder.Stop(); err != nil {
+		t.Fatal(err)
+	}
+	if recordedUser != "map[access_token:server-token id:1]" || !bytes.Equal(recordedBinary, binaryContent) {
+		t.Fatalf("expected real responses in record mode, got: %s, %v", recordedUser, recordedBinary)
+	}
+
+	content, err := os.ReadFile(cfg.Path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, secret := range []string{"p4ssw0rd", "query-key", "bearer-token", "tenant-secret", "cookie-value", "server-token"} {
+		if bytes.Contains(content, []byte(secret)) {
+			t.Fatalf("expected %s is redacted in cassette, got:\n%s", secret, content)
+		}
+	}
+	if !bytes.Contains(content, []byte(`"bodyEncoding": "base64"`)) {
+		t.Fatalf("expected binary body is encoded with base64, got:\n%s", content)
+	}
+
+	cfg.Mode = ModeReplay
+	recorder, err = New(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	hc := newCassetteClient(t, server.URL, recorder)
+	replayedUser, replayedBinary := exchange(t, hc)
+	if replayedUser != "map[access_token:[REDACTED] id:1]" || !bytes.Equal(replayedBinary, binaryContent) {
+		t.Fatalf("expected recorded responses in replay mode, got: %s, %v", replayedUser, replayedBinary)
+	}
+	if atomic.LoadInt32(calls) != 2 || len(recorder.Unused()) != 0 {
+		t.Fatalf("expected all interactions are replayed without network, got %d calls, %d unused",
+			atomic.LoadInt32(calls), len(recorder.Unused()))
+	}
+	// Each interaction is replayed once.
+	if _, err := hc.GetWithContext(context.Background(), "/binary", nil); !errors.Is(err, ErrInteractionNotFound) {
+		t.Fatalf("expected no interaction left, got: %v", err)
+	}
+}
+
+func TestReplayOrRecord(t *testing.T) {
+	server, calls := newCassetteServer(t)
+	path := filepath.Join(t.TempDir(), "binary.json")
+	if _, err := New(Config{Path: path, Mode: ModeReplay}); err == nil {
+		t.Fatal("expected cassette file is required in replay mode")
+	}
+
+	for i := 0; i < 2; i++ {
+		recorder, err := New(Config{Path: path, Mode: ModeReplayOrRecord})
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp, err := newCassetteClient(t, server.URL, recorder).GetWithContext(context.Background(), "/binary", nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		body, _ := io.ReadAll(resp.Body)
+		resp.Body.Close()
+		if !bytes.Equal(body, binaryContent) {
+			t.Fatalf("expected binary content, got: %v", body)
+		}
+		if err := recorder.Stop(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if atomic.LoadInt32(calls) != 1 {
+		t.Fatalf("expected the second run is replayed, got %d calls", atomic.LoadInt32(calls))
+	}
+	content, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Count(string(content), `"method"`) != 1 {
+		t.Fatalf("expected 1 recorded interaction, got:\n%s", content)
+	}
+}
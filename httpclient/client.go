@@ -2,6 +2,7 @@ package httpclient
 
 import (
 	"bytes"
+	"context"
 	jsonlib "encoding/json"
 	"fmt"
 	"github.com/sirupsen/logrus"
@@ -20,19 +21,17 @@ type HTTPClient struct {
 	scheme, host string
 	// Scheme://Host:Port or Scheme://Host
 	Address string
-}
-
-type errServerResponse struct {
-	Success  bool           `json:"success"`
-	Message  string         `json:"message"`
-	Response *http.Response `json:"-"`
-}
 
-func (esr *errServerResponse) setFieldValue(success bool, message string, response *http.Response) *errServerResponse {
-	esr.Success = success
-	esr.Message = message
-	esr.Response = response
-	return esr
+	retry          *retryConfig
+	breakers       *circuitBreakers
+	decoders       map[string]Decoder
+	interceptors   []Interceptor
+	defaultHeaders map[string]string
+	debug          *debugLogger
+	auth           AuthProvider
+	limiters       *limiters
+	cache          CacheStorage
+	tracing        *tracing
 }
 
 // LowerLevelClientOption is a customizable option for initialize lower level http.Client.
@@ -44,13 +43,6 @@ type RequestOption func(*http.Request) error
 // Option is a customizable option for initialize HTTPClient, details also refer to: WithAddress, etc.
 type Option func(*HTTPClient) error
 
-var (
-	defaultHeaders = map[string]string{
-		"Accept":     "*/*",
-		"Connection": "keep-alive",
-	}
-)
-
 // WithAddress can overwrite the client request address with specified one address.
 func WithAddress(address string) Option {
 	return func(hc *HTTPClient) error {
@@ -72,7 +64,7 @@ func WithAddress(address string) Option {
 	}
 }
 
-// WithLowerLevelClientOption can initialize lower level http.Client.
+// WithLowerLevelClientOption can initialize lower level http.Client, which is owned by each HTTPClient.
 func WithLowerLevelClientOption(opt LowerLevelClientOption) Option {
 	return func(hc *HTTPClient) error {
 		if err := opt(hc.client); err != nil {
@@ -85,7 +77,9 @@ func WithLowerLevelClientOption(opt LowerLevelClientOption) Option {
 // NewHTTPClientWithOptions will initialize HTTPClient with series of Option, design inspired by docker/docker.
 func NewHTTPClientWithOptions(opts ...Option) (*HTTPClient, error) {
 	hc := &HTTPClient{
-		client: http.DefaultClient,
+		client:         newDefaultClient(),
+		debug:          debugLoggerFromEnv(),
+		defaultHeaders: defaultHeaders,
 	}
 	for _, opt := range opts {
 		if err := opt(hc); err != nil {
@@ -95,18 +89,22 @@ func NewHTTPClientWithOptions(opts ...Option) (*HTTPClient, error) {
 	return hc, nil
 }
 
-func (hc *HTTPClient) newRequest(method, path string, body io.Reader) (*http.Request, error) {
+func (hc *HTTPClient) newRequest(ctx context.Context, method, path string, body io.Reader) (*http.Request, error) {
 	logrus.Debugf("request address: %s, path: %s, method: %s", hc.Address, path, method)
 	expectedPayload := method == http.MethodPost || method == http.MethodPatch || method == http.MethodPut
 	if expectedPayload && body == nil {
 		body = bytes.NewReader(nil)
 	}
-	req, err := http.NewRequest(method, path, body)
+	url, err := hc.ResolveURL(path)
+	if err != nil {
+		return nil, err
+	}
+	req, err := http.NewRequestWithContext(ctx, method, url, body)
 	if err != nil {
 		return nil, fmt.Errorf("unable to initialize go origin http request, error: %s", err)
 	}
-	for key, value := range defaultHeaders {
-		req.Header.Set(key, value)
+	if multipartBody, ok := body.(*lazyPipeReader); ok {
+		req.GetBody = multipartBody.form.getBody
 	}
 	if expectedPayload && req.Header.Get(livingkit.ContentType) == "" {
 		req.Header.Set(livingkit.ContentType, livingkit.TextPlain)
@@ -116,7 +114,17 @@ func (hc *HTTPClient) newRequest(method, path string, body io.Reader) (*http.Req
 
 // DoRequest will do real request.
 func (hc *HTTPClient) DoRequest(method, path string, body io.Reader, reqOpts ...RequestOption) (*http.Response, error) {
-	req, err := hc.newRequest(method, path, body)
+	return hc.DoRequestWithContext(context.Background(), method, path, body, reqOpts...)
+}
+
+// DoRequestWithContext will do real request with the given context, cancellation and deadline of ctx are applied to
+// the transport and the retry loop. E.g.: pass `c.Request.Context()` in gin handler to stop outbound request when the
+// inbound request is cancelled.
+func (hc *HTTPClient) DoRequestWithContext(ctx context.Context, method, path string, body io.Reader, reqOpts ...RequestOption) (*http.Response, error) {
+	if ctx == nil {
+		return nil, fmt.Errorf("nil context")
+	}
+	req, err := hc.newRequest(ctx, method, path, body)
 	if err != nil {
 		return nil, fmt.Errorf("unable to initialize request, error: %s", err)
 	}
@@ -126,15 +134,45 @@ func (hc *HTTPClient) DoRequest(method, path string, body io.Reader, reqOpts ...
 		}
 	}
 	startedTime := time.Now()
-	response, err := hc.client.Do(req)
+	response, err := hc.invoke(req)
 	logrus.Debugf("request elapsed time: %s", time.Since(startedTime).String())
 	if err != nil {
-		return nil, fmt.Errorf("request (%s:%s) failed, error: %s", path, method, err)
+		return nil, fmt.Errorf("request (%s:%s) failed, error: %w", path, method, err)
 	}
-	DumpVerboseRequestResponse(os.Stderr, req, response)
 	return response, nil
 }
 
+// send sends request with lower level http.Client, and retries it if WithRetry is enabled.
+func (hc *HTTPClient) send(req *http.Request) (*http.Response, error) {
+	if hc.retry == nil || !hc.retry.retryableRequest(req) {
+		return hc.sendOnce(req)
+	}
+	return hc.retry.do(hc.sendOnce, req)
+}
+
+// sendOnce sends request only once, it goes through circuit breaker if WithCircuitBreaker is enabled, and then waits
+// for limiters if WithRateLimit or WithMaxInFlight is enabled, so that open breaker fails fast without waiting.
+func (hc *HTTPClient) sendOnce(req *http.Request) (*http.Response, error) {
+	scheme, host := req.URL.Scheme, req.URL.Host
+	if host == "" {
+		scheme, host = hc.scheme, hc.host
+	}
+	doFunc := hc.client.Do
+	if hc.limiters != nil {
+		next := doFunc
+		doFunc = func(req *http.Request) (*http.Response, error) {
+			return hc.limiters.do(scheme, host, req, next)
+		}
+	}
+	if hc.breakers != nil {
+		next := doFunc
+		doFunc = func(req *http.Request) (*http.Response, error) {
+			return hc.breakers.do(host, req, next)
+		}
+	}
+	return doFunc(req)
+}
+
 // OK checks if status code of the response is between [200, 400), this will return true if OK.
 func (hc *HTTPClient) OK(resp *http.Response) bool {
 	if http.StatusOK <= resp.StatusCode && resp.StatusCode < http.StatusBadRequest {
@@ -143,34 +181,33 @@ func (hc *HTTPClient) OK(resp *http.Response) bool {
 	return false
 }
 
-// HandleResponse converts http.Response to given entity. If HTTP code is not [200, 400), it will
-func (hc *HTTPClient) HandleResponse(resp *http.Response, entity interface{}) *errServerResponse {
-	defer func() {
-		if !resp.Close {
-			resp.Body.Close()
-		}
-	}()
+// HandleResponse converts http.Response to given entity with the decoder chosen by response Content-Type, see WithDecoder.
+// If HTTP code is not [200, 400) or response can't be read or decoded, it will return *HTTPError which can be
+// inspected with errors.As. Nil entity or empty body will skip decoding.
+func (hc *HTTPClient) HandleResponse(resp *http.Response, entity interface{}) error {
+	defer resp.Body.Close()
 
-	var esr = new(errServerResponse)
 	b := new(bytes.Buffer)
 	if _, err := b.ReadFrom(resp.Body); err != nil {
-		return esr.setFieldValue(false, fmt.Sprintf("unable to read response, error: %s", err), resp)
+		return newHTTPError(resp, b.Bytes(), fmt.Errorf("unable to read response, error: %w", err))
 	}
 	if hc.OK(resp) {
-		if err := jsonlib.Unmarshal(b.Bytes(), &entity); err != nil {
-			return esr.setFieldValue(false, fmt.Sprintf("unable to unmarshal json response, error: %s", err), resp)
+		if entity == nil || b.Len() == 0 {
+			return nil
+		}
+		if err := hc.decoder(resp.Header.Get(livingkit.ContentType)).Decode(b.Bytes(), entity); err != nil {
+			return newHTTPError(resp, b.Bytes(), fmt.Errorf("unable to decode response, error: %w", err))
 		}
 		return nil
 	}
-	if err := jsonlib.Unmarshal(b.Bytes(), &esr); err != nil {
-		return esr.setFieldValue(false, fmt.Sprintf("unable to unmarshal json error response, error: %s", err), resp)
-	}
-	esr.Response = resp
-	return esr
+	httpErr := newHTTPError(resp, b.Bytes(), nil)
+	httpErr.parseBody()
+	return httpErr
 }
 
 // PrepareBody prepares the given HTTP body data for POST/PUT/PATCH generally, refer to requests/models.py:PrepareRequest.prepare_body.
 // https://learning.postman.com/docs/sending-requests/requests/#sending-body-data
+// For multipart/form-data body, see PrepareMultipartBody.
 func (hc *HTTPClient) PrepareBody(json interface{}, data urllib.Values) (io.Reader, string, error) {
 	var (
 		body        io.Reader
@@ -191,6 +228,18 @@ func (hc *HTTPClient) PrepareBody(json interface{}, data urllib.Values) (io.Read
 	return body, contentType, nil
 }
 
+// PrepareMultipartBody prepares the streaming multipart/form-data body, content type contains the boundary.
+func (hc *HTTPClient) PrepareMultipartBody(form *MultipartForm) (io.Reader, string, error) {
+	if form == nil {
+		return nil, "", fmt.Errorf("nil multipart form")
+	}
+	body, contentType, err := form.Reader()
+	if err != nil {
+		return nil, "", fmt.Errorf("invalid multipart form, error: %s", err)
+	}
+	return body, contentType, nil
+}
+
 // PreparePath prepares the given path and query string to new api. If origin path contains query params, new params will append.
 func (hc *HTTPClient) PreparePath(path string, params urllib.Values) (string, error) {
 	if params == nil {
@@ -208,7 +257,65 @@ func (hc *HTTPClient) PreparePath(path string, params urllib.Values) (string, er
 	return u.String(), nil
 }
 
-func (hc *HTTPClient) commonPostPatchPut(method, path string, json interface{}, data urllib.Values, opts ...RequestOption) (*http.Response, error) {
+// ResolveURL resolves the relative path against HTTPClient.Address, absolute URL or empty Address will pass through.
+// Unlike RFC 3986 reference resolution, base path of Address is always kept, e.g.: `https://api/internal/` with
+// `/v1/users` or `v1/users` resolves to `https://api/internal/v1/users`.
+func (hc *HTTPClient) ResolveURL(path string) (string, error) {
+	u, err := urllib.Parse(path)
+	if err != nil {
+		return "", fmt.Errorf("unable to parse path: %s, error: %s", path, err)
+	}
+	if u.IsAbs() || u.Host != "" || hc.Address == "" {
+		return path, nil
+	}
+	base, err := urllib.Parse(hc.Address)
+	if err != nil {
+		return "", fmt.Errorf("unable to parse address, error: %s", err)
+	}
+	escapedPath := strings.TrimSuffix(base.EscapedPath(), "/")
+	if relative := u.EscapedPath(); relative != "" {
+		escapedPath = fmt.Sprintf("%s/%s", escapedPath, strings.TrimPrefix(relative, "/"))
+	}
+	if base.Path, err = urllib.PathUnescape(escapedPath); err != nil {
+		return "", fmt.Errorf("unable to unescape path: %s, error: %s", escapedPath, err)
+	}
+	base.RawPath = escapedPath
+	switch {
+	case base.RawQuery == "":
+		base.RawQuery = u.RawQuery
+	case u.RawQuery != "":
+		base.RawQuery = fmt.Sprintf("%s&%s", base.RawQuery, u.RawQuery)
+	}
+	base.Fragment = u.Fragment
+	return base.String(), nil
+}
+
+// ExpandPath replaces `{name}` placeholders of the path template with the escaped value of params, e.g.:
+// `/users/{id}` with `{"id": "a/b"}` expands to `/users/a%2Fb`. It returns error if any placeholder has no value.
+func ExpandPath(template string, params map[string]string) (string, error) {
+	var builder strings.Builder
+	for {
+		start := strings.IndexByte(template, '{')
+		if start < 0 {
+			builder.WriteString(template)
+			return builder.String(), nil
+		}
+		end := strings.IndexByte(template[start:], '}')
+		if end < 0 {
+			return "", fmt.Errorf("unclosed path param in template: %s", template)
+		}
+		name := template[start+1 : start+end]
+		value, ok := params[name]
+		if !ok {
+			return "", fmt.Errorf("missing path param: %s", name)
+		}
+		builder.WriteString(template[:start])
+		builder.WriteString(urllib.PathEscape(value))
+		template = template[start+end+1:]
+	}
+}
+
+func (hc *HTTPClient) commonPostPatchPut(ctx context.Context, method, path string, json interface{}, data urllib.Values, opts ...RequestOption) (*http.Response, error) {
 	switch method {
 	case http.MethodGet, http.MethodDelete:
 		logrus.Warningf("method: %s recommends that should not body", method)
@@ -221,42 +328,94 @@ func (hc *HTTPClient) commonPostPatchPut(method, path string, json interface{},
 		request.Header.Set(livingkit.ContentType, ct)
 		return nil
 	})
-	return hc.DoRequest(method, path, body, opts...)
+	return hc.DoRequestWithContext(ctx, method, path, body, opts...)
 }
 
 // Post sends a http.MethodPost request.
 func (hc *HTTPClient) Post(path string, json interface{}, data urllib.Values, opts ...RequestOption) (*http.Response, error) {
-	return hc.commonPostPatchPut(http.MethodPost, path, json, data, opts...)
+	return hc.commonPostPatchPut(context.Background(), http.MethodPost, path, json, data, opts...)
+}
+
+// PostWithContext sends a http.MethodPost request with the given context.
+func (hc *HTTPClient) PostWithContext(ctx context.Context, path string, json interface{}, data urllib.Values, opts ...RequestOption) (*http.Response, error) {
+	return hc.commonPostPatchPut(ctx, http.MethodPost, path, json, data, opts...)
+}
+
+// Put sends a http.MethodPut request.
+func (hc *HTTPClient) Put(path string, json interface{}, data urllib.Values, opts ...RequestOption) (*http.Response, error) {
+	return hc.commonPostPatchPut(context.Background(), http.MethodPut, path, json, data, opts...)
+}
+
+// PutWithContext sends a http.MethodPut request with the given context.
+func (hc *HTTPClient) PutWithContext(ctx context.Context, path string, json interface{}, data urllib.Values, opts ...RequestOption) (*http.Response, error) {
+	return hc.commonPostPatchPut(ctx, http.MethodPut, path, json, data, opts...)
 }
 
 // Patch sends a http.MethodPatch request.
 func (hc *HTTPClient) Patch(path string, json interface{}, data urllib.Values, opts ...RequestOption) (*http.Response, error) {
-	return hc.commonPostPatchPut(http.MethodPatch, path, json, data, opts...)
+	return hc.commonPostPatchPut(context.Background(), http.MethodPatch, path, json, data, opts...)
 }
 
-func (hc *HTTPClient) commonGetDelete(method, path string, params urllib.Values, opts ...RequestOption) (*http.Response, error) {
-	switch {
-	case method != http.MethodGet, method != http.MethodDelete:
+// PatchWithContext sends a http.MethodPatch request with the given context.
+func (hc *HTTPClient) PatchWithContext(ctx context.Context, path string, json interface{}, data urllib.Values, opts ...RequestOption) (*http.Response, error) {
+	return hc.commonPostPatchPut(ctx, http.MethodPatch, path, json, data, opts...)
+}
+
+func (hc *HTTPClient) commonGetDelete(ctx context.Context, method, path string, params urllib.Values, opts ...RequestOption) (*http.Response, error) {
+	switch method {
+	case http.MethodPost, http.MethodPatch, http.MethodPut:
 		logrus.Warningf("method: %s recommends that should not URL query params", method)
 	}
 	path, err := hc.PreparePath(path, params)
 	if err != nil {
 		return nil, fmt.Errorf("unable to prepare path: %s", path)
 	}
-	return hc.DoRequest(method, path, nil, opts...)
+	return hc.DoRequestWithContext(ctx, method, path, nil, opts...)
 }
 
 // Get sends a http.MethodGet request.
 func (hc *HTTPClient) Get(path string, params urllib.Values, opts ...RequestOption) (*http.Response, error) {
-	return hc.commonGetDelete(http.MethodGet, path, params, opts...)
+	return hc.commonGetDelete(context.Background(), http.MethodGet, path, params, opts...)
+}
+
+// GetWithContext sends a http.MethodGet request with the given context.
+func (hc *HTTPClient) GetWithContext(ctx context.Context, path string, params urllib.Values, opts ...RequestOption) (*http.Response, error) {
+	return hc.commonGetDelete(ctx, http.MethodGet, path, params, opts...)
 }
 
 // Delete sends a http.MethodDelete request.
 func (hc *HTTPClient) Delete(path string, params urllib.Values, opts ...RequestOption) (*http.Response, error) {
-	return hc.commonGetDelete(http.MethodDelete, path, params, opts...)
+	return hc.commonGetDelete(context.Background(), http.MethodDelete, path, params, opts...)
+}
+
+// DeleteWithContext sends a http.MethodDelete request with the given context.
+func (hc *HTTPClient) DeleteWithContext(ctx context.Context, path string, params urllib.Values, opts ...RequestOption) (*http.Response, error) {
+	return hc.commonGetDelete(ctx, http.MethodDelete, path, params, opts...)
+}
+
+// Head sends a http.MethodHead request.
+func (hc *HTTPClient) Head(path string, params urllib.Values, opts ...RequestOption) (*http.Response, error) {
+	return hc.commonGetDelete(context.Background(), http.MethodHead, path, params, opts...)
+}
+
+// HeadWithContext sends a http.MethodHead request with the given context.
+func (hc *HTTPClient) HeadWithContext(ctx context.Context, path string, params urllib.Values, opts ...RequestOption) (*http.Response, error) {
+	return hc.commonGetDelete(ctx, http.MethodHead, path, params, opts...)
+}
+
+// Options sends a http.MethodOptions request.
+func (hc *HTTPClient) Options(path string, params urllib.Values, opts ...RequestOption) (*http.Response, error) {
+	return hc.commonGetDelete(context.Background(), http.MethodOptions, path, params, opts...)
+}
+
+// OptionsWithContext sends a http.MethodOptions request with the given context.
+func (hc *HTTPClient) OptionsWithContext(ctx context.Context, path string, params urllib.Values, opts ...RequestOption) (*http.Response, error) {
+	return hc.commonGetDelete(ctx, http.MethodOptions, path, params, opts...)
 }
 
 // DumpVerboseRequestResponse returns the given request and response in its HTTP/1.x wire representation.
+//
+// Deprecated: it dumps sensitive headers and body verbatim, use WithDebug instead.
 func DumpVerboseRequestResponse(w io.Writer, req *http.Request, resp *http.Response) {
 	includeBody := os.Getenv(livingkit.DebugHTTPClientBody) != ""
 	if os.Getenv(livingkit.DebugHTTPClient) == "" {
@@ -267,7 +426,7 @@ func DumpVerboseRequestResponse(w io.Writer, req *http.Request, resp *http.Respo
 		fmt.Fprintln(w, strings.Repeat(">", 100))
 		bytes.NewBuffer(dump).WriteTo(w)
 		if includeBody {
-			fmt.Println()
+			fmt.Fprintln(w)
 		}
 	}
 	dump, err = httputil.DumpResponse(resp, includeBody)
@@ -0,0 +1,66 @@
+package httpclient
+
+import (
+	"testing"
+)
+
+func TestResolveURL(t *testing.T) {
+	cases := []struct {
+		address, path, expected string
+	}{
+		{address: "https://api", path: "/v1/users", expected: "https://api/v1/users"},
+		{address: "https://api/", path: "v1/users", expected: "https://api/v1/users"},
+		{address: "https://api/internal", path: "/v1/users", expected: "https://api/internal/v1/users"},
+		{address: "https://api/internal/", path: "/v1/users/", expected: "https://api/internal/v1/users/"},
+		{address: "https://api/internal/", path: "v1/users", expected: "https://api/internal/v1/users"},
+		{address: "https://api/internal", path: "", expected: "https://api/internal"},
+		{address: "https://api/my%2Fspace", path: "/files/a%2Fb", expected: "https://api/my%2Fspace/files/a%2Fb"},
+		{address: "https://api/internal?region=us", path: "/v1/users?page=2#top", expected: "https://api/internal/v1/users?region=us&page=2#top"},
+		{address: "https://api/internal", path: "https://other/v1/users", expected: "https://other/v1/users"},
+		{address: "https://api/internal", path: "//other/v1/users", expected: "//other/v1/users"},
+		{address: "", path: "/v1/users", expected: "/v1/users"},
+	}
+	for _, c := range cases {
+		var opts []Option
+		if c.address != "" {
+			opts = append(opts, WithAddress(c.address))
+		}
+		hc, err := NewHTTPClientWithOptions(opts...)
+		if err != nil {
+			t.Fatal(err)
+		}
+		actual, err := hc.ResolveURL(c.path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if actual != c.expected {
+			t.Fatalf("expected %s with %s resolves to %s, got: %s", c.address, c.path, c.expected, actual)
+		}
+	}
+}
+
+func TestExpandPath(t *testing.T) {
+	params := map[string]string{"tenant": "a/b", "id": "my file?#", "empty": ""}
+	cases := []struct {
+		template, expected string
+	}{
+		{template: "/v1/tenants/{tenant}/users/{id}", expected: "/v1/tenants/a%2Fb/users/my%20file%3F%23"},
+		{template: "/v1/{id}{id}", expected: "/v1/my%20file%3F%23my%20file%3F%23"},
+		{template: "/v1/users/{empty}", expected: "/v1/users/"},
+		{template: "/v1/users", expected: "/v1/users"},
+	}
+	for _, c := range cases {
+		actual, err := ExpandPath(c.template, params)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if actual != c.expected {
+			t.Fatalf("expected %s expands to %s, got: %s", c.template, c.expected, actual)
+		}
+	}
+	for _, template := range []string{"/v1/{missing}", "/v1/{id"} {
+		if _, err := ExpandPath(template, params); err == nil {
+			t.Fatalf("expected error of template: %s", template)
+		}
+	}
+}
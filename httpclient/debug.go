@@ -0,0 +1,172 @@
+package httpclient
+
+import (
+	"bytes"
+	"fmt"
+	"github.com/sirupsen/logrus"
+	"github.com/uddmorningsun/go-livingkit"
+	"io"
+	"mime"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+)
+
+const defaultMaxBodySize = 4096
+
+// DebugOptions configures request and response logging of WithDebug.
+type DebugOptions struct {
+	// Writer receives the log entries, default is os.Stderr.
+	Writer io.Writer
+	// Formatter formats the log entries, default is logrus.TextFormatter.
+	Formatter logrus.Formatter
+	// IncludeBody logs request and response body, multipart body is always omitted.
+	IncludeBody bool
+	// MaxBodySize truncates the logged body after redaction, default is 4096 bytes. JSON or form response body over it
+	// is omitted since the peeked part can't be redacted.
+	MaxBodySize int
+	// RedactHeaders are redacted in addition to DefaultRedactHeaders, case-insensitive.
+	RedactHeaders []string
+	// RedactFields are redacted in addition to DefaultRedactFields, case-insensitive.
+	RedactFields []string
+}
+
+type debugLogger struct {
+	logger      *logrus.Logger
+	includeBody bool
+	maxBodySize int
+	redactor    *Redactor
+}
+
+// WithDebug logs every request and response with structured logrus fields (method, url, status, latency, etc.),
+// sensitive headers and fields are redacted. It replaces the wire dumps enabled by DEBUG_HTTPCLIENT environment.
+func WithDebug(opts DebugOptions) Option {
+	return func(hc *HTTPClient) error {
+		if opts.MaxBodySize < 0 {
+			return fmt.Errorf("invalid debug option, 'MaxBodySize' should not be negative")
+		}
+		hc.debug = newDebugLogger(opts)
+		return nil
+	}
+}
+
+func newDebugLogger(opts DebugOptions) *debugLogger {
+	logger := logrus.New()
+	logger.SetLevel(logrus.DebugLevel)
+	logger.SetOutput(os.Stderr)
+	if opts.Writer != nil {
+		logger.SetOutput(opts.Writer)
+	}
+	if opts.Formatter != nil {
+		logger.SetFormatter(opts.Formatter)
+	}
+	if opts.MaxBodySize == 0 {
+		opts.MaxBodySize = defaultMaxBodySize
+	}
+	return &debugLogger{
+		logger:      logger,
+		includeBody: opts.IncludeBody,
+		maxBodySize: opts.MaxBodySize,
+		redactor:    NewRedactor(opts.RedactHeaders, opts.RedactFields),
+	}
+}
+
+// debugLoggerFromEnv keeps compatible with DEBUG_HTTPCLIENT and DEBUG_HTTPCLIENT_BODY environment.
+func debugLoggerFromEnv() *debugLogger {
+	if os.Getenv(livingkit.DebugHTTPClient) == "" {
+		return nil
+	}
+	return newDebugLogger(DebugOptions{IncludeBody: os.Getenv(livingkit.DebugHTTPClientBody) != ""})
+}
+
+// interceptor logs the request and response, it's the innermost interceptor so that the final request is logged.
+func (dl *debugLogger) interceptor(req *http.Request, next Invoker) (*http.Response, error) {
+	fields := logrus.Fields{
+		"method":         req.Method,
+		"url":            dl.redactor.URL(req.URL),
+		"requestHeaders": dl.redactor.Header(req.Header),
+	}
+	if dl.includeBody {
+		fields["requestBody"] = dl.requestBody(req)
+	}
+	startedTime := time.Now()
+	resp, err := next(req)
+	fields["latency"] = time.Since(startedTime).String()
+	if err != nil {
+		dl.logger.WithFields(fields).WithError(err).Debug("http request failed")
+		return resp, err
+	}
+	fields["status"] = resp.StatusCode
+	fields["responseHeaders"] = dl.redactor.Header(resp.Header)
+	if dl.includeBody {
+		fields["responseBody"] = dl.responseBody(resp)
+	}
+	dl.logger.WithFields(fields).Debug("http request done")
+	return resp, nil
+}
+
+// redactBody redacts the whole body before truncating, so that fields before the cut-off point are never logged.
+func (dl *debugLogger) redactBody(contentType string, body []byte) string {
+	redacted := dl.redactor.Body(contentType, body)
+	if len(redacted) > dl.maxBodySize {
+		return fmt.Sprintf("%s...(truncated)", redacted[:dl.maxBodySize])
+	}
+	return string(redacted)
+}
+
+func (dl *debugLogger) requestBody(req *http.Request) string {
+	contentType := req.Header.Get(livingkit.ContentType)
+	switch {
+	case req.Body == nil || req.Body == http.NoBody:
+		return ""
+	case strings.HasPrefix(contentType, livingkit.MultipartFormData):
+		return "(multipart body omitted)"
+	case req.GetBody == nil:
+		return "(unreplayable body omitted)"
+	}
+	body, err := req.GetBody()
+	if err != nil {
+		return fmt.Sprintf("(unable to read body: %s)", err)
+	}
+	defer body.Close()
+	// Replayable body is in memory, JSON or form body is read fully so that it can be parsed and redacted.
+	reader := io.LimitReader(body, int64(dl.maxBodySize)+1)
+	if redactableBody(contentType) {
+		reader = body
+	}
+	payload, err := io.ReadAll(reader)
+	if err != nil {
+		return fmt.Sprintf("(unable to read body: %s)", err)
+	}
+	return dl.redactBody(contentType, payload)
+}
+
+// responseBody peeks the response body and puts it back, so that caller can still read the whole body.
+func (dl *debugLogger) responseBody(resp *http.Response) string {
+	contentType := resp.Header.Get(livingkit.ContentType)
+	// Peeking would block until the stream sends enough data.
+	if mediaType, _, _ := mime.ParseMediaType(contentType); streamingMediaTypes[mediaType] {
+		return "(stream body omitted)"
+	}
+	payload, err := io.ReadAll(io.LimitReader(resp.Body, int64(dl.maxBodySize)+1))
+	resp.Body = &multiReadCloser{Reader: io.MultiReader(bytes.NewReader(payload), resp.Body), Closer: resp.Body}
+	if err != nil {
+		return fmt.Sprintf("(unable to read body: %s)", err)
+	}
+	// The peeked part of JSON or form body can't be parsed, it's omitted rather than logged without redaction.
+	if len(payload) > dl.maxBodySize && redactableBody(contentType) {
+		if resp.ContentLength > 0 {
+			return fmt.Sprintf("<%d bytes redacted>", resp.ContentLength)
+		}
+		return fmt.Sprintf("<more than %d bytes redacted>", dl.maxBodySize)
+	}
+	return dl.redactBody(contentType, payload)
+}
+
+var streamingMediaTypes = map[string]bool{livingkit.TextEventStream: true, livingkit.ApplicationNDJSON: true, "application/jsonl": true}
+
+type multiReadCloser struct {
+	io.Reader
+	io.Closer
+}
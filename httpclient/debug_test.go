@@ -0,0 +1,49 @@
+package httpclient
+
+import (
+	"bytes"
+	"context"
+	"github.com/uddmorningsun/go-livingkit"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestDebugRedactsTruncatedBody(t *testing.T) {
+	padding := strings.Repeat("a", 256)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.Header().Set(livingkit.ContentType, livingkit.ApplicationJSON)
+		_, _ = w.Write([]byte(`{"token":"s3cr3t","z_pad":"` + padding + `"}`))
+	}))
+	defer server.Close()
+
+	var output bytes.Buffer
+	hc, err := NewHTTPClientWithOptions(
+		WithAddress(server.URL), WithDebug(DebugOptions{Writer: &output, IncludeBody: true, MaxBodySize: 64}),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	body := `{"password":"hunter2","z_pad":"` + padding + `"}`
+	resp, err := hc.DoRequestWithContext(context.Background(), http.MethodPost, "/", strings.NewReader(body), func(req *http.Request) error {
+		req.Header.Set(livingkit.ContentType, livingkit.ApplicationJSON)
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	drainBody(resp)
+
+	logged := output.String()
+	for _, secret := range []string{"hunter2", "s3cr3t"} {
+		if strings.Contains(logged, secret) {
+			t.Fatalf("expected %s is redacted, got: %s", secret, logged)
+		}
+	}
+	for _, expected := range []string{`[REDACTED]`, `...(truncated)`, `<285 bytes redacted>`} {
+		if !strings.Contains(logged, expected) {
+			t.Fatalf("expected %s in log, got: %s", expected, logged)
+		}
+	}
+}
@@ -0,0 +1,192 @@
+package httpclient
+
+import (
+	"bytes"
+	"encoding"
+	jsonlib "encoding/json"
+	"encoding/xml"
+	"fmt"
+	"github.com/uddmorningsun/go-livingkit"
+	"io"
+	"mime"
+	"net/http"
+	"strings"
+)
+
+const (
+	applicationXML         = "application/xml"
+	textXML                = "text/xml"
+	applicationOctetStream = "application/octet-stream"
+	// maxErrorMessageBody is the maximum body length used as HTTPError message if error response is not JSON.
+	maxErrorMessageBody = 512
+)
+
+// Decoder decodes response body to the given entity.
+type Decoder interface {
+	Decode(data []byte, entity interface{}) error
+}
+
+// DecoderFunc is an adapter to allow the use of ordinary functions as Decoder.
+type DecoderFunc func(data []byte, entity interface{}) error
+
+// Decode calls f(data, entity).
+func (f DecoderFunc) Decode(data []byte, entity interface{}) error {
+	return f(data, entity)
+}
+
+var (
+	// JSONDecoder decodes JSON body.
+	JSONDecoder Decoder = DecoderFunc(jsonlib.Unmarshal)
+	// XMLDecoder decodes XML body.
+	XMLDecoder Decoder = DecoderFunc(xml.Unmarshal)
+	// TextDecoder decodes body to *string, *[]byte, io.Writer or encoding.TextUnmarshaler, other entities are decoded as
+	// JSON since many APIs send JSON body with wrong content type.
+	TextDecoder Decoder = DecoderFunc(decodeText)
+	// BytesDecoder decodes body to *[]byte, *string or io.Writer without any conversion, other entities are decoded as
+	// JSON as TextDecoder.
+	BytesDecoder Decoder = DecoderFunc(decodeBytes)
+
+	defaultDecoders = map[string]Decoder{
+		livingkit.ApplicationJSON: JSONDecoder,
+		applicationXML:            XMLDecoder,
+		textXML:                   XMLDecoder,
+		livingkit.TextPlain:       TextDecoder,
+		applicationOctetStream:    BytesDecoder,
+	}
+)
+
+func decodeText(data []byte, entity interface{}) error {
+	if unmarshaler, ok := entity.(encoding.TextUnmarshaler); ok {
+		return unmarshaler.UnmarshalText(data)
+	}
+	return decodeBytes(data, entity)
+}
+
+func decodeBytes(data []byte, entity interface{}) error {
+	switch value := entity.(type) {
+	case *[]byte:
+		*value = append((*value)[:0], data...)
+	case *string:
+		*value = string(data)
+	case io.Writer:
+		_, err := value.Write(data)
+		return err
+	default:
+		return jsonlib.Unmarshal(data, entity)
+	}
+	return nil
+}
+
+// WithDecoder registers decoder of the media type (e.g.: `application/json`), it overwrites the default decoders
+// which supports JSON, XML, plain text and raw bytes.
+func WithDecoder(mediaType string, decoder Decoder) Option {
+	return func(hc *HTTPClient) error {
+		if mediaType == "" || decoder == nil {
+			return fmt.Errorf("required media type and decoder")
+		}
+		if hc.decoders == nil {
+			hc.decoders = make(map[string]Decoder)
+		}
+		hc.decoders[strings.ToLower(mediaType)] = decoder
+		return nil
+	}
+}
+
+// decoder chooses the decoder by Content-Type of the response, JSONDecoder is used if none matched.
+// Structured syntax suffix is also supported, e.g.: `application/problem+json`.
+func (hc *HTTPClient) decoder(contentType string) Decoder {
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return JSONDecoder
+	}
+	candidates := []string{mediaType}
+	if index := strings.LastIndexByte(mediaType, '+'); index >= 0 {
+		candidates = append(candidates, fmt.Sprintf("application/%s", mediaType[index+1:]))
+	}
+	for _, candidate := range candidates {
+		if decoder, ok := hc.decoders[candidate]; ok {
+			return decoder
+		}
+		if decoder, ok := defaultDecoders[candidate]; ok {
+			return decoder
+		}
+	}
+	return JSONDecoder
+}
+
+// HTTPError is returned by HandleResponse if HTTP code is not [200, 400) or response can't be read or decoded.
+// The `success`, `code` and `message` fields of JSON error response (e.g.: ErrorCode of ginlib.ResponseError) are parsed
+// into Success, Code and Message.
+type HTTPError struct {
+	StatusCode int         `json:"-"`
+	Status     string      `json:"-"`
+	Header     http.Header `json:"-"`
+	// Body is the raw response body.
+	Body     []byte         `json:"-"`
+	Success  bool           `json:"success"`
+	Code     int            `json:"code"`
+	Message  string         `json:"message"`
+	Response *http.Response `json:"-"`
+	// Err is the underlying error if response can't be read or decoded.
+	Err error `json:"-"`
+}
+
+func newHTTPError(resp *http.Response, body []byte, err error) *HTTPError {
+	return &HTTPError{
+		StatusCode: resp.StatusCode,
+		Status:     resp.Status,
+		Header:     resp.Header,
+		Body:       body,
+		Response:   resp,
+		Err:        err,
+	}
+}
+
+func (e *HTTPError) Error() string {
+	if e.Err != nil {
+		return fmt.Sprintf("handle response (%s) failed, error: %s", e.Status, e.Err)
+	}
+	if e.Message != "" {
+		return fmt.Sprintf("server response (%s): %s", e.Status, e.Message)
+	}
+	return fmt.Sprintf("server response (%s)", e.Status)
+}
+
+// Unwrap returns the underlying error.
+func (e *HTTPError) Unwrap() error {
+	return e.Err
+}
+
+// readHTTPError reads the whole body of the unexpected response like HandleResponse, and parses it into HTTPError.
+func readHTTPError(resp *http.Response) *HTTPError {
+	b := new(bytes.Buffer)
+	if _, err := b.ReadFrom(resp.Body); err != nil {
+		return newHTTPError(resp, b.Bytes(), fmt.Errorf("unable to read response, error: %w", err))
+	}
+	httpErr := newHTTPError(resp, b.Bytes(), nil)
+	httpErr.parseBody()
+	return httpErr
+}
+
+// parseBody parses `success`, `code` and `message` fields from JSON body, or uses body as message if it's not JSON.
+func (e *HTTPError) parseBody() {
+	if err := jsonlib.Unmarshal(e.Body, e); err == nil {
+		return
+	}
+	message := strings.TrimSpace(string(e.Body))
+	if len(message) > maxErrorMessageBody {
+		message = fmt.Sprintf("%s...", message[:maxErrorMessageBody])
+	}
+	e.Message = message
+}
+
+// DecodeResponse decodes the response to a value of type T, it's the generic version of HTTPClient.HandleResponse. E.g.:
+//
+//	users, err := httpclient.DecodeResponse[[]User](hc, resp)
+func DecodeResponse[T any](hc *HTTPClient, resp *http.Response) (T, error) {
+	var entity T
+	if err := hc.HandleResponse(resp, &entity); err != nil {
+		return entity, err
+	}
+	return entity, nil
+}
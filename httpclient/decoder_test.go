@@ -0,0 +1,132 @@
+package httpclient
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"github.com/uddmorningsun/go-livingkit"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+type decodedUser struct {
+	Name string `json:"name" xml:"name"`
+}
+
+func newDecoderServer(t *testing.T, responses map[string][2]string, statusCode int) *HTTPClient {
+	t.Helper()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		response := responses[req.URL.Path]
+		if response[0] != "" {
+			w.Header().Set(livingkit.ContentType, response[0])
+		}
+		w.WriteHeader(statusCode)
+		_, _ = w.Write([]byte(response[1]))
+	}))
+	t.Cleanup(server.Close)
+	hc, err := NewHTTPClientWithOptions(
+		WithAddress(server.URL),
+		WithDecoder("application/x-upper", DecoderFunc(func(data []byte, entity interface{}) error {
+			entity.(*decodedUser).Name = strings.ToUpper(string(data))
+			return nil
+		})),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return hc
+}
+
+func TestHandleResponseDecoder(t *testing.T) {
+	hc := newDecoderServer(t, map[string][2]string{
+		"/json":         {livingkit.ApplicationJSON, `{"name": "json"}`},
+		"/problem":      {"application/problem+json; charset=utf-8", `{"name": "problem"}`},
+		"/xml":          {applicationXML, `<user><name>xml</name></user>`},
+		"/text":         {livingkit.TextPlain, `{"name": "text"}`},
+		"/octet-stream": {applicationOctetStream, `{"name": "octet-stream"}`},
+		"/custom":       {"application/x-upper", `custom`},
+	}, http.StatusOK)
+
+	cases := map[string]string{
+		"/json": "json", "/problem": "problem", "/xml": "xml", "/text": "text", "/octet-stream": "octet-stream",
+		"/custom": "CUSTOM",
+	}
+	for path, expected := range cases {
+		resp, err := hc.GetWithContext(context.Background(), path, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		user, err := DecodeResponse[decodedUser](hc, resp)
+		if err != nil || user.Name != expected {
+			t.Fatalf("expected %s decoded to %q, got: %+v, %v", path, expected, user, err)
+		}
+	}
+
+	for _, entity := range []interface{}{new(string), new([]byte), new(bytes.Buffer)} {
+		resp, err := hc.GetWithContext(context.Background(), "/text", nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := hc.HandleResponse(resp, entity); err != nil {
+			t.Fatal(err)
+		}
+		var actual string
+		switch value := entity.(type) {
+		case *string:
+			actual = *value
+		case *[]byte:
+			actual = string(*value)
+		case *bytes.Buffer:
+			actual = value.String()
+		}
+		if actual != `{"name": "text"}` {
+			t.Fatalf("expected raw text body of %T, got: %q", entity, actual)
+		}
+	}
+}
+
+func TestHandleResponseHTTPError(t *testing.T) {
+	long := strings.Repeat("x", maxErrorMessageBody+10)
+	hc := newDecoderServer(t, map[string][2]string{
+		"/json": {livingkit.ApplicationJSON, `{"success": false, "code": 1001, "message": "resource not found"}`},
+		"/text": {livingkit.TextPlain, long},
+	}, http.StatusNotFound)
+
+	resp, err := hc.GetWithContext(context.Background(), "/json", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = hc.HandleResponse(resp, nil)
+	var httpErr *HTTPError
+	if !errors.As(err, &httpErr) || httpErr.StatusCode != http.StatusNotFound || httpErr.Code != 1001 || httpErr.Err != nil {
+		t.Fatalf("expected parsed HTTPError, got: %#v", err)
+	}
+	if expected := "server response (404 Not Found): resource not found"; err.Error() != expected {
+		t.Fatalf("expected error: %s, got: %s", expected, err)
+	}
+
+	resp, err = hc.GetWithContext(context.Background(), "/text", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := hc.HandleResponse(resp, nil); !errors.As(err, &httpErr) || httpErr.Message != long[:maxErrorMessageBody]+"..." {
+		t.Fatalf("expected truncated text message, got: %v", err)
+	}
+	if len(httpErr.Body) != len(long) {
+		t.Fatalf("expected raw body kept, got %d bytes", len(httpErr.Body))
+	}
+}
+
+func TestHandleResponseDecodeError(t *testing.T) {
+	hc := newDecoderServer(t, map[string][2]string{"/json": {livingkit.ApplicationJSON, `{"name": `}}, http.StatusOK)
+	resp, err := hc.GetWithContext(context.Background(), "/json", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var httpErr *HTTPError
+	if err := hc.HandleResponse(resp, &decodedUser{}); !errors.As(err, &httpErr) || httpErr.Err == nil || errors.Unwrap(err) == nil {
+		t.Fatalf("expected HTTPError with decode error, got: %v", err)
+	}
+}
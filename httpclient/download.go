@@ -0,0 +1,221 @@
+package httpclient
+
+import (
+	"context"
+	"crypto/md5"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"hash"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// ErrChecksumMismatch is returned by HTTPClient.Download if checksum of the content is not the expected one.
+var ErrChecksumMismatch = errors.New("checksum mismatch")
+
+var checksumAlgorithms = map[string]func() hash.Hash{
+	"md5":    md5.New,
+	"sha1":   sha1.New,
+	"sha256": sha256.New,
+	"sha512": sha512.New,
+}
+
+// DownloadOptions configures HTTPClient.Download.
+type DownloadOptions struct {
+	// Offset resumes a previous download with `Range: bytes=<Offset>-` header, w should already hold the first Offset
+	// bytes, e.g.: a file opened with os.O_APPEND.
+	Offset int64
+	// Checksum verifies the whole content, format is `<algorithm>:<hex digest>`, algorithm is one of md5, sha1, sha256
+	// and sha512. If Offset is set, w must implement io.ReaderAt to hash the existing content, e.g.: *os.File.
+	Checksum string
+	// MaxResumes resumes the interrupted transfer from the received bytes up to MaxResumes times.
+	MaxResumes int
+	// OnProgress is called after each write with the bytes held by w (including Offset) and the total size, total is
+	// -1 if unknown.
+	OnProgress func(written, total int64)
+	Options    []RequestOption
+}
+
+type downloader struct {
+	hc   *HTTPClient
+	path string
+	w    io.Writer
+	hash hash.Hash
+	opts DownloadOptions
+	// offset is the bytes held by w.
+	offset int64
+	total  int64
+	// validator is the strong ETag or Last-Modified of the first response, it's sent with `If-Range` header when
+	// resuming, so that the content is not mixed if it changes.
+	validator string
+}
+
+// Download streams the response body of a GET request to w without buffering, it returns the bytes written by this
+// call. See DownloadOptions for resume and checksum verification.
+func (hc *HTTPClient) Download(ctx context.Context, path string, w io.Writer, opts DownloadOptions) (int64, error) {
+	if opts.Offset < 0 {
+		return 0, fmt.Errorf("invalid download option, 'Offset' should not be negative")
+	}
+	d := &downloader{hc: hc, path: path, w: w, opts: opts, offset: opts.Offset, total: -1}
+	var expected string
+	if opts.Checksum != "" {
+		algorithm, digest, found := strings.Cut(opts.Checksum, ":")
+		newHash, ok := checksumAlgorithms[strings.ToLower(algorithm)]
+		if !found || !ok {
+			return 0, fmt.Errorf("invalid checksum: %s, format should be '<algorithm>:<hex digest>'", opts.Checksum)
+		}
+		d.hash, expected = newHash(), strings.ToLower(digest)
+		if opts.Offset > 0 {
+			existing, ok := w.(io.ReaderAt)
+			if !ok {
+				return 0, fmt.Errorf("unable to verify checksum of resumed download, writer should implement io.ReaderAt")
+			}
+			if _, err := io.Copy(d.hash, io.NewSectionReader(existing, 0, opts.Offset)); err != nil {
+				return 0, fmt.Errorf("unable to read downloaded content, error: %s", err)
+			}
+		}
+	}
+
+	for resumes := 0; ; resumes++ {
+		resumable, err := d.fetch(ctx)
+		if err == nil {
+			break
+		}
+		if !resumable || resumes >= opts.MaxResumes || ctx.Err() != nil {
+			return d.offset - opts.Offset, err
+		}
+	}
+	if d.hash != nil {
+		if actual := hex.EncodeToString(d.hash.Sum(nil)); actual != expected {
+			return d.offset - opts.Offset, fmt.Errorf("%w, expected: %s, actual: %s", ErrChecksumMismatch, expected, actual)
+		}
+	}
+	return d.offset - opts.Offset, nil
+}
+
+// fetch requests the content from offset and writes it to w, it reports whether the download can be resumed if error
+// happens.
+func (d *downloader) fetch(ctx context.Context) (bool, error) {
+	opts := append([]RequestOption{func(req *http.Request) error {
+		if d.offset > 0 {
+			req.Header.Set("Range", fmt.Sprintf("bytes=%d-", d.offset))
+			if d.validator != "" {
+				req.Header.Set("If-Range", d.validator)
+			}
+		}
+		return nil
+	}}, d.opts.Options...)
+	resp, err := d.hc.DoRequestWithContext(ctx, http.MethodGet, d.path, nil, opts...)
+	if err != nil {
+		return true, err
+	}
+	defer resp.Body.Close()
+
+	switch resp.StatusCode {
+	case http.StatusPartialContent:
+		start, total, err := parseContentRange(resp.Header.Get("Content-Range"))
+		if err != nil {
+			return false, newHTTPError(resp, nil, err)
+		}
+		if start != d.offset {
+			return false, newHTTPError(resp, nil, fmt.Errorf("unexpected range start: %d, expected: %d", start, d.offset))
+		}
+		d.total = total
+	case http.StatusOK:
+		validator := responseValidator(resp)
+		if d.offset > 0 && d.validator != "" && validator != d.validator {
+			return false, newHTTPError(resp, nil, fmt.Errorf("content changed while resuming download"))
+		}
+		// Server ignores the range, skip the bytes held by w.
+		if d.offset > 0 {
+			if _, err := io.CopyN(io.Discard, resp.Body, d.offset); err != nil {
+				return true, fmt.Errorf("unable to skip downloaded content, error: %w", err)
+			}
+		}
+		d.total = resp.ContentLength
+	case http.StatusRequestedRangeNotSatisfiable:
+		// The content is already complete.
+		if _, total, err := parseContentRange(resp.Header.Get("Content-Range")); err == nil && total == d.offset {
+			d.total = total
+			return false, nil
+		}
+		fallthrough
+	default:
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, maxErrorMessageBody))
+		httpErr := newHTTPError(resp, body, nil)
+		httpErr.parseBody()
+		return false, httpErr
+	}
+	if d.validator == "" {
+		d.validator = responseValidator(resp)
+	}
+
+	buffer := make([]byte, 32*1024)
+	for {
+		n, readErr := resp.Body.Read(buffer)
+		if n > 0 {
+			if _, err := d.w.Write(buffer[:n]); err != nil {
+				return false, fmt.Errorf("unable to write downloaded content, error: %w", err)
+			}
+			if d.hash != nil {
+				d.hash.Write(buffer[:n])
+			}
+			d.offset += int64(n)
+			if d.opts.OnProgress != nil {
+				d.opts.OnProgress(d.offset, d.total)
+			}
+		}
+		if readErr == io.EOF {
+			break
+		}
+		if readErr != nil {
+			return true, fmt.Errorf("unable to read downloaded content, error: %w", readErr)
+		}
+	}
+	if d.total >= 0 && d.offset != d.total {
+		return true, fmt.Errorf("unable to read downloaded content, error: %w", io.ErrUnexpectedEOF)
+	}
+	return false, nil
+}
+
+// responseValidator returns strong ETag or Last-Modified which can be used with `If-Range` header.
+func responseValidator(resp *http.Response) string {
+	if etag := resp.Header.Get("ETag"); etag != "" && !strings.HasPrefix(etag, "W/") {
+		return etag
+	}
+	return resp.Header.Get("Last-Modified")
+}
+
+// parseContentRange parses `bytes <start>-<end>/<total>` or `bytes */<total>`, total is -1 if it's unknown.
+func parseContentRange(value string) (int64, int64, error) {
+	if !strings.HasPrefix(value, "bytes ") {
+		return 0, 0, fmt.Errorf("invalid content range: %s", value)
+	}
+	positions, size, found := strings.Cut(strings.TrimPrefix(value, "bytes "), "/")
+	if !found {
+		return 0, 0, fmt.Errorf("invalid content range: %s", value)
+	}
+	total := int64(-1)
+	if size != "*" {
+		parsed, err := strconv.ParseInt(size, 10, 64)
+		if err != nil {
+			return 0, 0, fmt.Errorf("invalid content range: %s", value)
+		}
+		total = parsed
+	}
+	if positions == "*" {
+		return 0, total, nil
+	}
+	first, _, found := strings.Cut(positions, "-")
+	start, err := strconv.ParseInt(first, 10, 64)
+	if !found || err != nil {
+		return 0, 0, fmt.Errorf("invalid content range: %s", value)
+	}
+	return start, total, nil
+}
@@ -0,0 +1,103 @@
+package httpclient
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strconv"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+var downloadContent = bytes.Repeat([]byte("0123456789abcdef"), 8*1024)
+
+func downloadChecksum(content []byte) string {
+	sum := sha256.Sum256(content)
+	return "sha256:" + hex.EncodeToString(sum[:])
+}
+
+// newDownloadServer serves downloadContent with Range support, the first full response is aborted halfway if interrupt.
+func newDownloadServer(t *testing.T, interrupt bool) (*HTTPClient, *int32) {
+	t.Helper()
+	var calls int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		call := atomic.AddInt32(&calls, 1)
+		w.Header().Set("ETag", `"v1"`)
+		if interrupt && call == 1 {
+			w.Header().Set("Content-Length", strconv.Itoa(len(downloadContent)))
+			_, _ = w.Write(downloadContent[:len(downloadContent)/2])
+			w.(http.Flusher).Flush()
+			panic(http.ErrAbortHandler)
+		}
+		http.ServeContent(w, req, "content", time.Time{}, bytes.NewReader(downloadContent))
+	}))
+	t.Cleanup(server.Close)
+	hc, err := NewHTTPClientWithOptions(WithAddress(server.URL))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return hc, &calls
+}
+
+func TestDownloadResumesInterruptedTransfer(t *testing.T) {
+	hc, calls := newDownloadServer(t, true)
+	var buffer bytes.Buffer
+	n, err := hc.Download(context.Background(), "/", &buffer, DownloadOptions{
+		Checksum:   downloadChecksum(downloadContent),
+		MaxResumes: 1,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != int64(len(downloadContent)) || !bytes.Equal(buffer.Bytes(), downloadContent) || atomic.LoadInt32(calls) != 2 {
+		t.Fatalf("expected whole content after 1 resume, got %d bytes after %d calls", n, atomic.LoadInt32(calls))
+	}
+}
+
+func TestDownloadWithOffset(t *testing.T) {
+	hc, _ := newDownloadServer(t, false)
+	offset := len(downloadContent) / 3
+	file, err := os.Create(filepath.Join(t.TempDir(), "content"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+	if _, err := file.Write(downloadContent[:offset]); err != nil {
+		t.Fatal(err)
+	}
+	var progress int64
+	n, err := hc.Download(context.Background(), "/", file, DownloadOptions{
+		Offset:     int64(offset),
+		Checksum:   downloadChecksum(downloadContent),
+		OnProgress: func(written, total int64) { progress = written },
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	content, err := os.ReadFile(file.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != int64(len(downloadContent)-offset) || progress != int64(len(downloadContent)) || !bytes.Equal(content, downloadContent) {
+		t.Fatalf("expected the rest %d bytes resumed, got %d bytes, progress: %d", len(downloadContent)-offset, n, progress)
+	}
+}
+
+func TestDownloadChecksumMismatch(t *testing.T) {
+	hc, _ := newDownloadServer(t, false)
+	var buffer bytes.Buffer
+	_, err := hc.Download(context.Background(), "/", &buffer, DownloadOptions{Checksum: downloadChecksum([]byte("other"))})
+	if !errors.Is(err, ErrChecksumMismatch) {
+		t.Fatalf("expected checksum mismatch, got: %v", err)
+	}
+	if _, err := hc.Download(context.Background(), "/", &buffer, DownloadOptions{Checksum: "crc32:00"}); err == nil {
+		t.Fatal("expected unsupported checksum algorithm error")
+	}
+}
@@ -0,0 +1,116 @@
+package httpclient
+
+import (
+	"fmt"
+	"github.com/uddmorningsun/go-livingkit"
+	"net/http"
+)
+
+const (
+	// HeaderRequestID is the default header of RequestIDInterceptor.
+	HeaderRequestID = "X-Request-Id"
+	headerUserAgent = "User-Agent"
+)
+
+// defaultHeaders are installed to every HTTPClient unless they are replaced by WithDefaultHeaders.
+var defaultHeaders = map[string]string{
+	"Accept":     "*/*",
+	"Connection": "keep-alive",
+}
+
+// Invoker sends the request and returns the response, it's the next step of an Interceptor.
+type Invoker func(req *http.Request) (*http.Response, error)
+
+// Interceptor wraps every request of HTTPClient, it can modify request, inspect response or error returned by next, or
+// short-circuit by returning without calling next.
+// Interceptors wrap the whole call, retries and circuit breaker happen inside next.
+type Interceptor func(req *http.Request, next Invoker) (*http.Response, error)
+
+// WithInterceptors appends interceptors to HTTPClient, the first one is the outermost.
+func WithInterceptors(interceptors ...Interceptor) Option {
+	return func(hc *HTTPClient) error {
+		for _, interceptor := range interceptors {
+			if interceptor == nil {
+				return fmt.Errorf("nil interceptor")
+			}
+		}
+		hc.interceptors = append(hc.interceptors, interceptors...)
+		return nil
+	}
+}
+
+// WithDefaultHeaders replaces the default headers (`Accept: */*` and `Connection: keep-alive`) which are set if request
+// doesn't have them, nil drops them. E.g.: drop them and add DefaultHeadersInterceptor by WithInterceptors to order it
+// with other interceptors.
+func WithDefaultHeaders(headers map[string]string) Option {
+	return func(hc *HTTPClient) error {
+		hc.defaultHeaders = make(map[string]string, len(headers))
+		for key, value := range headers {
+			hc.defaultHeaders[key] = value
+		}
+		return nil
+	}
+}
+
+// invoke sends request through interceptors, default headers interceptor is the innermost one (except auth, debug
+// logging and cache) so that headers set by request options or other interceptors will not be overwritten.
+// Tracing is the outermost one except interceptors of WithInterceptors.
+func (hc *HTTPClient) invoke(req *http.Request) (*http.Response, error) {
+	invoker := Invoker(hc.send)
+	if hc.cache != nil {
+		invoker = chainInterceptor(hc.cacheInterceptor, invoker)
+	}
+	if hc.debug != nil {
+		invoker = chainInterceptor(hc.debug.interceptor, invoker)
+	}
+	if hc.auth != nil {
+		invoker = chainInterceptor(hc.authInterceptor, invoker)
+	}
+	if len(hc.defaultHeaders) > 0 {
+		invoker = chainInterceptor(DefaultHeadersInterceptor(hc.defaultHeaders), invoker)
+	}
+	if hc.tracing != nil {
+		invoker = chainInterceptor(hc.tracing.interceptor, invoker)
+	}
+	for i := len(hc.interceptors) - 1; i >= 0; i-- {
+		invoker = chainInterceptor(hc.interceptors[i], invoker)
+	}
+	return invoker(req)
+}
+
+func chainInterceptor(interceptor Interceptor, next Invoker) Invoker {
+	return func(req *http.Request) (*http.Response, error) {
+		return interceptor(req, next)
+	}
+}
+
+// DefaultHeadersInterceptor sets the given headers if request doesn't have them.
+func DefaultHeadersInterceptor(headers map[string]string) Interceptor {
+	return func(req *http.Request, next Invoker) (*http.Response, error) {
+		for key, value := range headers {
+			if req.Header.Get(key) == "" {
+				req.Header.Set(key, value)
+			}
+		}
+		return next(req)
+	}
+}
+
+// UserAgentInterceptor sets `User-Agent` header if request doesn't have it.
+func UserAgentInterceptor(userAgent string) Interceptor {
+	return DefaultHeadersInterceptor(map[string]string{headerUserAgent: userAgent})
+}
+
+// RequestIDInterceptor sets a UUID4 request ID to the given header (default is HeaderRequestID) if request doesn't
+// have it, so that the request can be traced in the logs of both sides.
+func RequestIDInterceptor(header string) Interceptor {
+	if header == "" {
+		header = HeaderRequestID
+	}
+	return func(req *http.Request, next Invoker) (*http.Response, error) {
+		if req.Header.Get(header) == "" {
+			req.Header.Set(header, livingkit.NewUUID4String())
+		}
+		return next(req)
+	}
+}
@@ -0,0 +1,56 @@
+package httpclient
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestDefaultHeaders(t *testing.T) {
+	received := make(chan http.Header, 1)
+	server := httptest.NewServer(http.HandlerFunc(func(_ http.ResponseWriter, req *http.Request) {
+		received <- req.Header.Clone()
+	}))
+	defer server.Close()
+
+	cases := []struct {
+		name     string
+		opts     []Option
+		expected map[string]string
+	}{
+		{name: "default", expected: map[string]string{"Accept": "*/*", "Connection": "keep-alive"}},
+		{name: "dropped", opts: []Option{WithDefaultHeaders(nil)}, expected: map[string]string{"Accept": "", "Connection": ""}},
+		{
+			name:     "replaced",
+			opts:     []Option{WithDefaultHeaders(map[string]string{"Accept": "application/json"})},
+			expected: map[string]string{"Accept": "application/json", "Connection": ""},
+		},
+		{
+			name: "interceptor",
+			opts: []Option{
+				WithDefaultHeaders(nil), WithInterceptors(DefaultHeadersInterceptor(map[string]string{"Accept": "text/plain"})),
+			},
+			expected: map[string]string{"Accept": "text/plain", "Connection": ""},
+		},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			hc, err := NewHTTPClientWithOptions(append([]Option{WithAddress(server.URL)}, c.opts...)...)
+			if err != nil {
+				t.Fatal(err)
+			}
+			resp, err := hc.GetWithContext(context.Background(), "/", nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			drainBody(resp)
+			header := <-received
+			for key, value := range c.expected {
+				if actual := header.Get(key); actual != value {
+					t.Fatalf("expected header %s: %q, got: %q", key, value, actual)
+				}
+			}
+		})
+	}
+}
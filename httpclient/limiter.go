@@ -0,0 +1,282 @@
+package httpclient
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	urllib "net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// LimiterError is returned if a request gives up waiting for WithRateLimit or WithMaxInFlight, e.g.: the wait would
+// exceed the deadline of request context. The request is not sent, so it's not counted by circuit breaker.
+type LimiterError struct {
+	Err error
+}
+
+func (e *LimiterError) Error() string {
+	return e.Err.Error()
+}
+
+// Unwrap returns the underlying error, e.g.: context.DeadlineExceeded.
+func (e *LimiterError) Unwrap() error {
+	return e.Err
+}
+
+// tokenBucket is a token bucket rate limiter which refills `rate` tokens per second up to `burst` tokens.
+type tokenBucket struct {
+	mu     sync.Mutex
+	rate   float64
+	burst  float64
+	tokens float64
+	last   time.Time
+}
+
+func newTokenBucket(qps float64, burst int) (*tokenBucket, error) {
+	if qps <= 0 {
+		return nil, fmt.Errorf("invalid rate limit, 'qps' should be greater than 0")
+	}
+	if burst <= 0 {
+		burst = 1
+	}
+	return &tokenBucket{rate: qps, burst: float64(burst), tokens: float64(burst), last: time.Now()}, nil
+}
+
+// reserve takes a token and returns how long to wait before the token is available.
+func (tb *tokenBucket) reserve(now time.Time) time.Duration {
+	tb.mu.Lock()
+	defer tb.mu.Unlock()
+	tb.tokens += now.Sub(tb.last).Seconds() * tb.rate
+	if tb.tokens > tb.burst {
+		tb.tokens = tb.burst
+	}
+	tb.last = now
+	tb.tokens--
+	if tb.tokens >= 0 {
+		return 0
+	}
+	return time.Duration(-tb.tokens / tb.rate * float64(time.Second))
+}
+
+// cancel gives back the reserved token.
+func (tb *tokenBucket) cancel() {
+	tb.mu.Lock()
+	defer tb.mu.Unlock()
+	tb.tokens++
+}
+
+// wait blocks until a token is available, it returns error right away if the wait would pass the deadline of ctx.
+func (tb *tokenBucket) wait(ctx context.Context) error {
+	now := time.Now()
+	delay := tb.reserve(now)
+	if delay == 0 {
+		return nil
+	}
+	if deadline, ok := ctx.Deadline(); ok && now.Add(delay).After(deadline) {
+		tb.cancel()
+		err := fmt.Errorf("rate limit wait %s would exceed context deadline: %w", delay.String(), context.DeadlineExceeded)
+		return &LimiterError{Err: err}
+	}
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		tb.cancel()
+		return &LimiterError{Err: fmt.Errorf("rate limit wait aborted: %w", ctx.Err())}
+	case <-timer.C:
+		return nil
+	}
+}
+
+type semaphore chan struct{}
+
+func (s semaphore) acquire(ctx context.Context) error {
+	select {
+	case s <- struct{}{}:
+		return nil
+	case <-ctx.Done():
+		return &LimiterError{Err: fmt.Errorf("max in-flight wait aborted: %w", ctx.Err())}
+	}
+}
+
+func (s semaphore) release() {
+	<-s
+}
+
+type limiters struct {
+	rate          *tokenBucket
+	hostRates     map[string]*tokenBucket
+	inflight      semaphore
+	hostInflights map[string]semaphore
+}
+
+func (hc *HTTPClient) getLimiters() *limiters {
+	if hc.limiters == nil {
+		hc.limiters = &limiters{hostRates: map[string]*tokenBucket{}, hostInflights: map[string]semaphore{}}
+	}
+	return hc.limiters
+}
+
+// WithRateLimit limits requests of all hosts with a token bucket of qps and burst (default 1).
+func WithRateLimit(qps float64, burst int) Option {
+	return func(hc *HTTPClient) error {
+		bucket, err := newTokenBucket(qps, burst)
+		if err != nil {
+			return err
+		}
+		hc.getLimiters().rate = bucket
+		return nil
+	}
+}
+
+// WithHostRateLimit limits requests of the given host with a token bucket of qps and burst (default 1), it works
+// together with WithRateLimit. Host is `host:port` (e.g.: `api.example.com:443`, the default port of scheme is used if
+// request URL has no port) or `host` which matches all ports, the former one is preferred if both match.
+func WithHostRateLimit(host string, qps float64, burst int) Option {
+	return func(hc *HTTPClient) error {
+		key, err := limiterHostKey(host)
+		if err != nil {
+			return err
+		}
+		bucket, err := newTokenBucket(qps, burst)
+		if err != nil {
+			return err
+		}
+		hc.getLimiters().hostRates[key] = bucket
+		return nil
+	}
+}
+
+// WithMaxInFlight limits concurrent requests of all hosts, a request is in-flight until its response body is read to EOF
+// or closed.
+func WithMaxInFlight(n int) Option {
+	return func(hc *HTTPClient) error {
+		if n <= 0 {
+			return fmt.Errorf("invalid max in-flight, it should be greater than 0")
+		}
+		hc.getLimiters().inflight = make(semaphore, n)
+		return nil
+	}
+}
+
+// WithHostMaxInFlight limits concurrent requests of the given host, it works together with WithMaxInFlight. Host is
+// matched as WithHostRateLimit.
+func WithHostMaxInFlight(host string, n int) Option {
+	return func(hc *HTTPClient) error {
+		key, err := limiterHostKey(host)
+		if err != nil {
+			return err
+		}
+		if n <= 0 {
+			return fmt.Errorf("invalid max in-flight, it should be greater than 0")
+		}
+		hc.getLimiters().hostInflights[key] = make(semaphore, n)
+		return nil
+	}
+}
+
+// limiterHostKey normalizes host of WithHostRateLimit and WithHostMaxInFlight to lower case `host:port` or `host`.
+func limiterHostKey(host string) (string, error) {
+	if host == "" || strings.ContainsAny(host, "/?#@") {
+		return "", fmt.Errorf("invalid limiter host: %q, it should be 'host:port' or 'host'", host)
+	}
+	hostname, port, err := net.SplitHostPort(host)
+	if err != nil {
+		// No port, IPv6 address may be enclosed in square brackets.
+		return strings.ToLower(strings.TrimSuffix(strings.TrimPrefix(host, "["), "]")), nil
+	}
+	if number, err := strconv.Atoi(port); err != nil || number <= 0 || number > 65535 {
+		return "", fmt.Errorf("invalid port of limiter host: %q", host)
+	}
+	return net.JoinHostPort(strings.ToLower(hostname), port), nil
+}
+
+// hostLimiter returns the limiter of the request host, `host:port` with the effective port is preferred over `host`.
+func hostLimiter[T any](limiters map[string]T, scheme, host string) T {
+	u := urllib.URL{Scheme: scheme, Host: host}
+	hostname, port := strings.ToLower(u.Hostname()), u.Port()
+	if port == "" {
+		port = defaultPorts[strings.ToLower(scheme)]
+	}
+	if limiter, ok := limiters[net.JoinHostPort(hostname, port)]; ok {
+		return limiter
+	}
+	return limiters[hostname]
+}
+
+var defaultPorts = map[string]string{"http": "80", "https": "443"}
+
+// do waits for rate limiters and in-flight semaphores of the host, then sends the request. Semaphores are released
+// when the response body is read to EOF, closed or request fails.
+func (l *limiters) do(scheme, host string, req *http.Request, doFunc func(*http.Request) (*http.Response, error)) (*http.Response, error) {
+	ctx := req.Context()
+	// Tokens taken are given back if a later limiter fails, since the request is not sent.
+	var taken []*tokenBucket
+	giveBack := func() {
+		for _, bucket := range taken {
+			bucket.cancel()
+		}
+	}
+	for _, bucket := range []*tokenBucket{l.rate, hostLimiter(l.hostRates, scheme, host)} {
+		if bucket == nil {
+			continue
+		}
+		if err := bucket.wait(ctx); err != nil {
+			giveBack()
+			return nil, err
+		}
+		taken = append(taken, bucket)
+	}
+	var acquired []semaphore
+	release := func() {
+		for _, sem := range acquired {
+			sem.release()
+		}
+	}
+	for _, sem := range []semaphore{l.inflight, hostLimiter(l.hostInflights, scheme, host)} {
+		if sem == nil {
+			continue
+		}
+		if err := sem.acquire(ctx); err != nil {
+			release()
+			giveBack()
+			return nil, err
+		}
+		acquired = append(acquired, sem)
+	}
+	resp, err := doFunc(req)
+	if err != nil {
+		release()
+		return nil, err
+	}
+	if len(acquired) > 0 {
+		resp.Body = &releaseOnCloseBody{ReadCloser: resp.Body, release: release}
+	}
+	return resp, nil
+}
+
+// releaseOnCloseBody releases semaphores when the body is read to EOF or closed, whichever comes first.
+type releaseOnCloseBody struct {
+	io.ReadCloser
+	once    sync.Once
+	release func()
+}
+
+func (b *releaseOnCloseBody) Read(p []byte) (int, error) {
+	n, err := b.ReadCloser.Read(p)
+	if err == io.EOF {
+		b.once.Do(b.release)
+	}
+	return n, err
+}
+
+func (b *releaseOnCloseBody) Close() error {
+	err := b.ReadCloser.Close()
+	b.once.Do(b.release)
+	return err
+}
@@ -0,0 +1,165 @@
+package httpclient
+
+import (
+	"context"
+	"errors"
+	"github.com/uddmorningsun/go-livingkit"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestRateLimitTimeoutIsNotBreakerFailure(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	hc, err := NewHTTPClientWithOptions(
+		WithAddress(server.URL), WithRateLimit(1, 1), WithCircuitBreaker(CircuitBreakerConfig{ConsecutiveFailures: 2}),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp, err := hc.GetWithContext(context.Background(), "/", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	drainBody(resp)
+	for i := 0; i < 2; i++ {
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+		_, err := hc.GetWithContext(ctx, "/", nil)
+		cancel()
+		var limiterErr *LimiterError
+		if !errors.As(err, &limiterErr) || !errors.Is(err, context.DeadlineExceeded) {
+			t.Fatalf("expected limiter deadline error, got: %v", err)
+		}
+	}
+	if state, _ := hc.CircuitState(strings.TrimPrefix(server.URL, "http://")); state != CircuitClosed {
+		t.Fatalf("expected limiter errors don't open the breaker, got: %s", state)
+	}
+}
+
+func TestHostMaxInFlight(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+	host := strings.TrimPrefix(server.URL, "http://")
+
+	for _, key := range []string{host, strings.Split(host, ":")[0]} {
+		t.Run(key, func(t *testing.T) {
+			hc, err := NewHTTPClientWithOptions(WithAddress(server.URL), WithHostMaxInFlight(key, 1))
+			if err != nil {
+				t.Fatal(err)
+			}
+			held, err := hc.GetWithContext(context.Background(), "/", nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+			defer cancel()
+			var limiterErr *LimiterError
+			if _, err := hc.GetWithContext(ctx, "/", nil); !errors.As(err, &limiterErr) {
+				t.Fatalf("expected max in-flight error, got: %v", err)
+			}
+			drainBody(held)
+			resp, err := hc.GetWithContext(context.Background(), "/", nil)
+			if err != nil {
+				t.Fatalf("expected closed body releases in-flight slot, got: %v", err)
+			}
+			drainBody(resp)
+		})
+	}
+}
+
+func TestHostLimiterKey(t *testing.T) {
+	limiters := map[string]string{}
+	for _, host := range []string{"API.example.com:443", "plain.example.com", "[::1]:8080"} {
+		key, err := limiterHostKey(host)
+		if err != nil {
+			t.Fatal(err)
+		}
+		limiters[key] = host
+	}
+	cases := []struct {
+		scheme, host, expected string
+	}{
+		{scheme: "https", host: "api.example.com", expected: "API.example.com:443"},
+		{scheme: "https", host: "api.example.com:443", expected: "API.example.com:443"},
+		{scheme: "http", host: "api.example.com", expected: ""},
+		{scheme: "http", host: "plain.example.com:8080", expected: "plain.example.com"},
+		{scheme: "http", host: "[::1]:8080", expected: "[::1]:8080"},
+	}
+	for _, c := range cases {
+		if actual := hostLimiter(limiters, c.scheme, c.host); actual != c.expected {
+			t.Fatalf("expected limiter %q of %s://%s, got: %q", c.expected, c.scheme, c.host, actual)
+		}
+	}
+
+	for _, host := range []string{"", "https://api.example.com", "api.example.com:https", "api.example.com:0"} {
+		if _, err := limiterHostKey(host); err == nil {
+			t.Fatalf("expected invalid limiter host: %q", host)
+		}
+	}
+}
+
+func TestMaxInFlightReleasedByConnectionClose(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.Header().Set("Connection", "close")
+		w.Header().Set(livingkit.ContentType, livingkit.ApplicationJSON)
+		_, _ = w.Write([]byte(`{"name": "livingkit"}`))
+	}))
+	defer server.Close()
+
+	hc, err := NewHTTPClientWithOptions(WithAddress(server.URL), WithMaxInFlight(1))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 3; i++ {
+		ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+		resp, err := hc.GetWithContext(ctx, "/", nil)
+		if err != nil {
+			cancel()
+			t.Fatalf("expected in-flight slot released of request %d, got: %v", i, err)
+		}
+		var entity struct{ Name string }
+		err = hc.HandleResponse(resp, &entity)
+		cancel()
+		if err != nil || !resp.Close || entity.Name != "livingkit" {
+			t.Fatalf("unexpected response of Connection: close, got: %+v, %v", entity, err)
+		}
+	}
+}
+
+func TestRateLimitTokenGivenBackIfHostLimitFails(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	hc, err := NewHTTPClientWithOptions(
+		WithAddress(server.URL),
+		WithRateLimit(0.001, 2),
+		WithHostRateLimit(strings.TrimPrefix(server.URL, "http://"), 0.001, 1),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp, err := hc.GetWithContext(context.Background(), "/", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	drainBody(resp)
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	var limiterErr *LimiterError
+	if _, err := hc.GetWithContext(ctx, "/", nil); !errors.As(err, &limiterErr) {
+		t.Fatalf("expected host rate limit error, got: %v", err)
+	}
+	if delay := hc.limiters.rate.reserve(time.Now()); delay != 0 {
+		t.Fatalf("expected global token given back, got wait: %s", delay)
+	}
+}
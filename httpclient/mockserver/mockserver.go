@@ -0,0 +1,360 @@
+// Package mockserver starts a local stub server with declarative expectations for testing code built on
+// httpclient.HTTPClient, e.g.:
+//
+//	server := mockserver.New(t)
+//	server.On(http.MethodGet, "/v1/users/{id}").Header("Authorization", "Bearer token").
+//		ReplyJSON(http.StatusOK, User{Name: "foo"}).Times(1)
+//	hc, err := httpclient.NewHTTPClientWithOptions(httpclient.WithAddress(server.URL))
+//
+// Expectations are verified and the server is closed when the test finishes.
+package mockserver
+
+import (
+	"bytes"
+	jsonlib "encoding/json"
+	"fmt"
+	"github.com/uddmorningsun/go-livingkit"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	urllib "net/url"
+	"reflect"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// Fault is an injected network failure of the response.
+type Fault int
+
+const (
+	// FaultCloseConnection closes the connection without response.
+	FaultCloseConnection Fault = iota + 1
+	// FaultTruncatedBody sends the headers and half of the body, then closes the connection.
+	FaultTruncatedBody
+)
+
+// Request is a request received by Server.
+type Request struct {
+	Method string
+	Path   string
+	Query  map[string][]string
+	Header http.Header
+	Body   []byte
+}
+
+// Expectation is an expected request and its canned response, see Server.On.
+type Expectation struct {
+	method   string
+	segments []string
+	matchers []func(req *http.Request, body []byte) bool
+
+	status  int
+	header  http.Header
+	body    []byte
+	handler http.HandlerFunc
+	delay   time.Duration
+	fault   Fault
+
+	// times is the expected calls, zero means at least once.
+	times    int
+	optional bool
+	calls    int
+}
+
+func (e *Expectation) String() string {
+	return fmt.Sprintf("%s /%s", e.method, strings.Join(e.segments, "/"))
+}
+
+// Query expects the URL query param has the value.
+func (e *Expectation) Query(key, value string) *Expectation {
+	e.matchers = append(e.matchers, func(req *http.Request, _ []byte) bool {
+		values, ok := req.URL.Query()[key]
+		return ok && len(values) > 0 && values[0] == value
+	})
+	return e
+}
+
+// Header expects the request header has the value.
+func (e *Expectation) Header(key, value string) *Expectation {
+	e.matchers = append(e.matchers, func(req *http.Request, _ []byte) bool {
+		return req.Header.Get(key) == value
+	})
+	return e
+}
+
+// JSONBody expects the request body is JSON semantically equal to the given value.
+func (e *Expectation) JSONBody(value interface{}) *Expectation {
+	expected, err := normalizeJSON(value)
+	e.matchers = append(e.matchers, func(_ *http.Request, body []byte) bool {
+		if err != nil {
+			return false
+		}
+		var actual interface{}
+		if err := jsonlib.Unmarshal(body, &actual); err != nil {
+			return false
+		}
+		return reflect.DeepEqual(expected, actual)
+	})
+	return e
+}
+
+// Match expects the custom matcher returns true, the request body can be read by the matcher.
+func (e *Expectation) Match(matcher func(req *http.Request) bool) *Expectation {
+	e.matchers = append(e.matchers, func(req *http.Request, body []byte) bool {
+		req.Body = io.NopCloser(bytes.NewReader(body))
+		return matcher(req)
+	})
+	return e
+}
+
+// Reply responds with the status code and body.
+func (e *Expectation) Reply(status int, body []byte) *Expectation {
+	e.status, e.body = status, body
+	return e
+}
+
+// ReplyJSON responds with the status code and JSON body of value.
+func (e *Expectation) ReplyJSON(status int, value interface{}) *Expectation {
+	body, err := jsonlib.Marshal(value)
+	if err != nil {
+		panic(fmt.Sprintf("mockserver: unable to marshal reply of %s, error: %s", e, err))
+	}
+	e.header.Set(livingkit.ContentType, livingkit.ApplicationJSON)
+	return e.Reply(status, body)
+}
+
+// ReplyHeader sets the response header.
+func (e *Expectation) ReplyHeader(key, value string) *Expectation {
+	e.header.Set(key, value)
+	return e
+}
+
+// ReplyFunc responds with the handler instead of canned response.
+func (e *Expectation) ReplyFunc(handler http.HandlerFunc) *Expectation {
+	e.handler = handler
+	return e
+}
+
+// Delay delays the response, e.g.: test timeout of client.
+func (e *Expectation) Delay(delay time.Duration) *Expectation {
+	e.delay = delay
+	return e
+}
+
+// Fault injects a network failure instead of the response.
+func (e *Expectation) Fault(fault Fault) *Expectation {
+	e.fault = fault
+	return e
+}
+
+// Times expects exactly n calls, the expectation stops matching after n calls so that the next expectation of the same
+// request can reply differently, e.g.: the first call fails and the retry succeeds.
+func (e *Expectation) Times(n int) *Expectation {
+	e.times = n
+	return e
+}
+
+// Maybe makes the expectation optional, it's not verified when the test finishes.
+func (e *Expectation) Maybe() *Expectation {
+	e.optional = true
+	return e
+}
+
+func (e *Expectation) matches(req *http.Request, body []byte) bool {
+	if e.method != req.Method || (e.times > 0 && e.calls >= e.times) {
+		return false
+	}
+	// Escaped path is split so that escaped slash, e.g.: `a%2Fb`, stays in one segment.
+	segments := strings.Split(strings.Trim(req.URL.EscapedPath(), "/"), "/")
+	if len(segments) != len(e.segments) {
+		return false
+	}
+	for i, segment := range e.segments {
+		if strings.HasPrefix(segment, "{") && strings.HasSuffix(segment, "}") {
+			if segments[i] == "" {
+				return false
+			}
+			continue
+		}
+		if unescaped, err := urllib.PathUnescape(segments[i]); err != nil || unescaped != segment {
+			return false
+		}
+	}
+	for _, matcher := range e.matchers {
+		if !matcher(req, body) {
+			return false
+		}
+	}
+	return true
+}
+
+// Server is a local stub server, it's safe for concurrent use.
+type Server struct {
+	// URL is the base URL of the server, e.g.: `http://127.0.0.1:54321`.
+	URL    string
+	t      testing.TB
+	server *httptest.Server
+
+	mu           sync.Mutex
+	expectations []*Expectation
+	requests     []*Request
+	unmatched    []*Request
+}
+
+// New starts a Server which is closed and verified by AssertExpectations when the test finishes.
+func New(t testing.TB) *Server {
+	s := &Server{t: t}
+	s.server = httptest.NewServer(http.HandlerFunc(s.serveHTTP))
+	s.URL = s.server.URL
+	t.Cleanup(func() {
+		s.server.Close()
+		s.AssertExpectations()
+	})
+	return s
+}
+
+// On adds an expectation of the method and path, path segment like `{id}` matches any value. By default the
+// expectation replies 200 with empty body and expects at least one call. Expectations are matched in order.
+func (s *Server) On(method, path string) *Expectation {
+	e := &Expectation{
+		method:   method,
+		segments: strings.Split(strings.Trim(path, "/"), "/"),
+		status:   http.StatusOK,
+		header:   make(http.Header),
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.expectations = append(s.expectations, e)
+	return e
+}
+
+// Calls returns the calls of the expectation.
+func (s *Server) Calls(e *Expectation) int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return e.calls
+}
+
+// Requests returns all received requests.
+func (s *Server) Requests() []*Request {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]*Request(nil), s.requests...)
+}
+
+// AssertExpectations reports test error for unmatched requests and expectations whose calls are not as expected.
+func (s *Server) AssertExpectations() bool {
+	s.t.Helper()
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	ok := true
+	for _, req := range s.unmatched {
+		s.t.Errorf("mockserver: unexpected request: %s %s", req.Method, req.Path)
+		ok = false
+	}
+	for _, e := range s.expectations {
+		switch {
+		case e.optional:
+		case e.times > 0 && e.calls != e.times:
+			s.t.Errorf("mockserver: expected %d calls of %s, got %d", e.times, e, e.calls)
+			ok = false
+		case e.calls == 0:
+			s.t.Errorf("mockserver: expected call of %s, got none", e)
+			ok = false
+		}
+	}
+	s.unmatched = nil
+	return ok
+}
+
+// Close closes the server, it's called automatically when the test finishes.
+func (s *Server) Close() {
+	s.server.Close()
+}
+
+func (s *Server) serveHTTP(w http.ResponseWriter, req *http.Request) {
+	body, _ := io.ReadAll(req.Body)
+	received := &Request{Method: req.Method, Path: req.URL.Path, Query: req.URL.Query(), Header: req.Header.Clone(), Body: body}
+
+	s.mu.Lock()
+	s.requests = append(s.requests, received)
+	var matched *Expectation
+	for _, e := range s.expectations {
+		if e.matches(req, body) {
+			matched = e
+			break
+		}
+	}
+	if matched == nil {
+		s.unmatched = append(s.unmatched, received)
+		s.mu.Unlock()
+		w.Header().Set(livingkit.ContentType, livingkit.ApplicationJSON)
+		w.WriteHeader(http.StatusNotImplemented)
+		_ = jsonlib.NewEncoder(w).Encode(map[string]interface{}{
+			"success": false,
+			"message": fmt.Sprintf("mockserver: no expectation of %s %s", req.Method, req.URL.Path),
+		})
+		return
+	}
+	matched.calls++
+	s.mu.Unlock()
+
+	if matched.delay > 0 {
+		timer := time.NewTimer(matched.delay)
+		select {
+		case <-req.Context().Done():
+			timer.Stop()
+			return
+		case <-timer.C:
+		}
+	}
+	req.Body = io.NopCloser(bytes.NewReader(body))
+	switch {
+	case matched.fault != 0:
+		s.injectFault(w, matched)
+	case matched.handler != nil:
+		matched.handler(w, req)
+	default:
+		for key, values := range matched.header {
+			w.Header()[key] = values
+		}
+		w.WriteHeader(matched.status)
+		_, _ = w.Write(matched.body)
+	}
+}
+
+func (s *Server) injectFault(w http.ResponseWriter, e *Expectation) {
+	hijacker, ok := w.(http.Hijacker)
+	if !ok {
+		s.t.Errorf("mockserver: unable to inject fault, response writer can't be hijacked")
+		return
+	}
+	conn, buffer, err := hijacker.Hijack()
+	if err != nil {
+		s.t.Errorf("mockserver: unable to inject fault, error: %s", err)
+		return
+	}
+	defer conn.Close()
+	if e.fault == FaultTruncatedBody {
+		fmt.Fprintf(buffer, "HTTP/1.1 %d %s\r\n", e.status, http.StatusText(e.status))
+		header := e.header.Clone()
+		header.Set("Content-Length", strconv.Itoa(len(e.body)))
+		_ = header.Write(buffer)
+		buffer.WriteString("\r\n")
+		_, _ = buffer.Write(e.body[:len(e.body)/2])
+		_ = buffer.Flush()
+	}
+}
+
+func normalizeJSON(value interface{}) (interface{}, error) {
+	content, err := jsonlib.Marshal(value)
+	if err != nil {
+		return nil, err
+	}
+	var normalized interface{}
+	err = jsonlib.Unmarshal(content, &normalized)
+	return normalized, err
+}
@@ -0,0 +1,34 @@
+package mockserver
+
+import (
+	"io"
+	"net/http"
+	"testing"
+)
+
+func TestEscapedPathSegment(t *testing.T) {
+	server := New(t)
+	file := server.On(http.MethodGet, "/files/{id}").Reply(http.StatusOK, []byte("file"))
+	space := server.On(http.MethodGet, "/my files/latest").Reply(http.StatusOK, []byte("latest"))
+
+	cases := []struct {
+		path, expected string
+	}{
+		{path: "/files/a%2Fb", expected: "file"},
+		{path: "/my%20files/latest", expected: "latest"},
+	}
+	for _, c := range cases {
+		resp, err := http.Get(server.URL + c.path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		body, _ := io.ReadAll(resp.Body)
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusOK || string(body) != c.expected {
+			t.Fatalf("expected %s matches, got: %s %q", c.path, resp.Status, body)
+		}
+	}
+	if server.Calls(file) != 1 || server.Calls(space) != 1 {
+		t.Fatalf("expected each expectation is called once, got: %d, %d", server.Calls(file), server.Calls(space))
+	}
+}
@@ -0,0 +1,214 @@
+package httpclient
+
+import (
+	"fmt"
+	"io"
+	"mime/multipart"
+	"net/textproto"
+	"strings"
+	"sync"
+)
+
+type multipartPart struct {
+	fieldName, value      string
+	fileName, contentType string
+	content               io.Reader
+	// offset is the start position of content if it's an io.Seeker, -1 means content can't be rewound.
+	offset int64
+}
+
+// MultipartForm builds a multipart/form-data body which mixes fields and files, the body is streamed through io.Pipe
+// so that large files will not be buffered in memory. E.g.:
+//
+//	file, _ := os.Open("backup.tar.gz")
+//	defer file.Close()
+//	form := httpclient.NewMultipartForm().
+//		Field("name", "backup").
+//		File("file", "backup.tar.gz", file).
+//		OnProgress(func(written int64) { logrus.Debugf("uploaded %d bytes", written) })
+//	body, contentType, err := hc.PrepareMultipartBody(form)
+//
+// Body can be rebuilt for retry or redirect only if all file contents implement io.Seeker.
+type MultipartForm struct {
+	parts []multipartPart
+	// boundary is kept for rebuilt body, since content type header has been set with it.
+	boundary   string
+	onProgress func(written int64)
+	err        error
+
+	mu sync.Mutex
+	// body is the last body returned by Reader, its writer goroutine must exit before file contents are rewound.
+	body *lazyPipeReader
+}
+
+// NewMultipartForm returns an empty MultipartForm.
+func NewMultipartForm() *MultipartForm {
+	return &MultipartForm{}
+}
+
+// Field adds a form field.
+func (mf *MultipartForm) Field(name, value string) *MultipartForm {
+	mf.parts = append(mf.parts, multipartPart{fieldName: name, value: value})
+	return mf
+}
+
+// File adds a file part with `application/octet-stream` content type, content will be read when body is sent.
+func (mf *MultipartForm) File(fieldName, fileName string, content io.Reader) *MultipartForm {
+	return mf.FileWithContentType(fieldName, fileName, "application/octet-stream", content)
+}
+
+// FileWithContentType adds a file part with the given content type.
+func (mf *MultipartForm) FileWithContentType(fieldName, fileName, contentType string, content io.Reader) *MultipartForm {
+	if content == nil {
+		mf.setError(fmt.Errorf("nil content of multipart file: %s", fileName))
+		return mf
+	}
+	part := multipartPart{fieldName: fieldName, fileName: fileName, contentType: contentType, content: content, offset: -1}
+	if seeker, ok := content.(io.Seeker); ok {
+		offset, err := seeker.Seek(0, io.SeekCurrent)
+		if err == nil {
+			part.offset = offset
+		}
+	}
+	mf.parts = append(mf.parts, part)
+	return mf
+}
+
+// OnProgress sets the callback which receives total bytes of the body written so far.
+func (mf *MultipartForm) OnProgress(fn func(written int64)) *MultipartForm {
+	mf.onProgress = fn
+	return mf
+}
+
+func (mf *MultipartForm) setError(err error) {
+	if mf.err == nil {
+		mf.err = err
+	}
+}
+
+// Reader returns the streaming body and its content type with boundary. Writing starts once the body is read, and
+// closing the body stops writing.
+func (mf *MultipartForm) Reader() (io.ReadCloser, string, error) {
+	if mf.err != nil {
+		return nil, "", mf.err
+	}
+	pr, pw := io.Pipe()
+	writer := multipart.NewWriter(&progressWriter{writer: pw, onProgress: mf.onProgress})
+	if mf.boundary == "" {
+		mf.boundary = writer.Boundary()
+	} else if err := writer.SetBoundary(mf.boundary); err != nil {
+		return nil, "", fmt.Errorf("unable to set multipart boundary, error: %s", err)
+	}
+	done := make(chan struct{})
+	body := &lazyPipeReader{PipeReader: pr, form: mf, done: done, start: func() {
+		go func() {
+			defer close(done)
+			_ = pw.CloseWithError(mf.writeTo(writer))
+		}()
+	}}
+	mf.mu.Lock()
+	mf.body = body
+	mf.mu.Unlock()
+	return body, writer.FormDataContentType(), nil
+}
+
+// rewind seeks all file contents back to their start position, so that Reader can be called again.
+func (mf *MultipartForm) rewind() error {
+	for _, part := range mf.parts {
+		if part.content == nil {
+			continue
+		}
+		if part.offset < 0 {
+			return fmt.Errorf("multipart file: %s can't be rewound since content is not io.Seeker", part.fileName)
+		}
+		if _, err := part.content.(io.Seeker).Seek(part.offset, io.SeekStart); err != nil {
+			return fmt.Errorf("unable to rewind multipart file: %s, error: %s", part.fileName, err)
+		}
+	}
+	return nil
+}
+
+// getBody is used as http.Request.GetBody to rebuild body for retry and redirect. The previous body may be still
+// written, e.g.: server rejects the upload before reading it, or transport closes the body asynchronously, so it's
+// stopped before rewinding the shared file contents.
+func (mf *MultipartForm) getBody() (io.ReadCloser, error) {
+	mf.mu.Lock()
+	previous := mf.body
+	mf.mu.Unlock()
+	if previous != nil {
+		previous.stop()
+	}
+	if err := mf.rewind(); err != nil {
+		return nil, err
+	}
+	body, _, err := mf.Reader()
+	return body, err
+}
+
+func (mf *MultipartForm) writeTo(writer *multipart.Writer) error {
+	for _, part := range mf.parts {
+		if part.content == nil {
+			if err := writer.WriteField(part.fieldName, part.value); err != nil {
+				return fmt.Errorf("unable to write multipart field: %s, error: %s", part.fieldName, err)
+			}
+			continue
+		}
+		header := make(textproto.MIMEHeader)
+		header.Set("Content-Disposition", fmt.Sprintf(
+			`form-data; name="%s"; filename="%s"`, escapeQuotes(part.fieldName), escapeQuotes(part.fileName),
+		))
+		header.Set("Content-Type", part.contentType)
+		w, err := writer.CreatePart(header)
+		if err == nil {
+			_, err = io.Copy(w, part.content)
+		}
+		if err != nil {
+			return fmt.Errorf("unable to write multipart file: %s, error: %s", part.fileName, err)
+		}
+	}
+	return writer.Close()
+}
+
+var quoteEscaper = strings.NewReplacer("\\", "\\\\", `"`, "\\\"")
+
+// escapeQuotes is copied from mime/multipart/writer.go which is unexported.
+func escapeQuotes(s string) string {
+	return quoteEscaper.Replace(s)
+}
+
+// lazyPipeReader starts the writer goroutine on first Read, so that no goroutine leaks if the body is never sent.
+type lazyPipeReader struct {
+	*io.PipeReader
+	form  *MultipartForm
+	once  sync.Once
+	start func()
+	// done is closed when the writer goroutine exits.
+	done chan struct{}
+}
+
+func (r *lazyPipeReader) Read(p []byte) (int, error) {
+	r.once.Do(r.start)
+	return r.PipeReader.Read(p)
+}
+
+// stop closes the body and waits for the writer goroutine to exit, the writer will not start if it hasn't.
+func (r *lazyPipeReader) stop() {
+	r.once.Do(func() { close(r.done) })
+	_ = r.PipeReader.Close()
+	<-r.done
+}
+
+type progressWriter struct {
+	writer     io.Writer
+	written    int64
+	onProgress func(written int64)
+}
+
+func (w *progressWriter) Write(p []byte) (int, error) {
+	n, err := w.writer.Write(p)
+	w.written += int64(n)
+	if w.onProgress != nil && n > 0 {
+		w.onProgress(w.written)
+	}
+	return n, err
+}
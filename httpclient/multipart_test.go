@@ -0,0 +1,59 @@
+package httpclient
+
+import (
+	"bytes"
+	"context"
+	"github.com/uddmorningsun/go-livingkit"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+)
+
+func TestMultipartFormRebuiltAfterRejectedUpload(t *testing.T) {
+	content := bytes.Repeat([]byte("0123456789abcdef"), 256*1024)
+	var (
+		calls    int32
+		uploaded = make(chan []byte, 1)
+	)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		// The first upload is rejected before its body is read.
+		if atomic.AddInt32(&calls, 1) == 1 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		file, _, err := req.FormFile("file")
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		defer file.Close()
+		payload, _ := io.ReadAll(file)
+		uploaded <- payload
+	}))
+	defer server.Close()
+
+	hc, err := NewHTTPClientWithOptions(WithAddress(server.URL), WithRetry(livingkit.RetryPolicy{MaxAttempts: 2}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	body, contentType, err := hc.PrepareMultipartBody(NewMultipartForm().File("file", "blob", bytes.NewReader(content)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp, err := hc.DoRequestWithContext(context.Background(), http.MethodPut, "/", body, func(req *http.Request) error {
+		req.Header.Set(livingkit.ContentType, contentType)
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("expected 200 of rebuilt upload, got: %s", resp.Status)
+	}
+	if payload := <-uploaded; !bytes.Equal(payload, content) {
+		t.Fatalf("expected %d bytes of rebuilt upload, got %d bytes", len(content), len(payload))
+	}
+}
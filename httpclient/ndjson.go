@@ -0,0 +1,89 @@
+package httpclient
+
+import (
+	"bufio"
+	"bytes"
+	jsonlib "encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+)
+
+// maxNDJSONLineSize is the maximum size of a single NDJSON line.
+const maxNDJSONLineSize = 10 << 20
+
+// NDJSONStream decodes newline delimited JSON (https://github.com/ndjson/ndjson-spec) of the response lazily, items are
+// decoded while the body is read, so that it works with large or endless responses.
+type NDJSONStream[T any] struct {
+	body    io.ReadCloser
+	scanner *bufio.Scanner
+	line    int
+	item    T
+	err     error
+}
+
+// StreamNDJSON returns a NDJSONStream of the response, it returns *HTTPError if HTTP code is not [200, 400). E.g.:
+//
+//	stream, err := httpclient.StreamNDJSON[Record](hc, resp)
+//	if err != nil {
+//		return err
+//	}
+//	defer stream.Close()
+//	for stream.Next() {
+//		record := stream.Item()
+//	}
+//	if err := stream.Err(); err != nil {
+//		return err
+//	}
+func StreamNDJSON[T any](hc *HTTPClient, resp *http.Response) (*NDJSONStream[T], error) {
+	if !hc.OK(resp) {
+		defer resp.Body.Close()
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, maxErrorMessageBody))
+		httpErr := newHTTPError(resp, body, nil)
+		httpErr.parseBody()
+		return nil, httpErr
+	}
+	scanner := bufio.NewScanner(resp.Body)
+	scanner.Buffer(make([]byte, 0, 64*1024), maxNDJSONLineSize)
+	return &NDJSONStream[T]{body: resp.Body, scanner: scanner}, nil
+}
+
+// Next decodes the next item, blank lines are skipped. It returns false at the end of body or error happens.
+func (s *NDJSONStream[T]) Next() bool {
+	if s.err != nil {
+		return false
+	}
+	for s.scanner.Scan() {
+		s.line++
+		line := bytes.TrimSpace(s.scanner.Bytes())
+		if len(line) == 0 {
+			continue
+		}
+		var item T
+		if err := jsonlib.Unmarshal(line, &item); err != nil {
+			s.err = fmt.Errorf("unable to decode line %d, error: %w", s.line, err)
+			return false
+		}
+		s.item = item
+		return true
+	}
+	if err := s.scanner.Err(); err != nil {
+		s.err = fmt.Errorf("unable to read line %d, error: %w", s.line+1, err)
+	}
+	return false
+}
+
+// Item returns the current item.
+func (s *NDJSONStream[T]) Item() T {
+	return s.item
+}
+
+// Err returns the error which stops decoding.
+func (s *NDJSONStream[T]) Err() error {
+	return s.err
+}
+
+// Close closes the response body.
+func (s *NDJSONStream[T]) Close() error {
+	return s.body.Close()
+}
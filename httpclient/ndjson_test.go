@@ -0,0 +1,71 @@
+package httpclient
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/uddmorningsun/go-livingkit"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestStreamNDJSON(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		w.Header().Set(livingkit.ContentType, livingkit.ApplicationNDJSON)
+		switch req.URL.Path {
+		case "/records":
+			fmt.Fprint(w, "{\"id\": 1}\n\n  \r\n{\"id\": 2}\r\n{\"id\": 3}")
+		case "/invalid":
+			fmt.Fprint(w, "{\"id\": 1}\n{\"id\": \n")
+		default:
+			w.Header().Set(livingkit.ContentType, livingkit.ApplicationJSON)
+			w.WriteHeader(http.StatusNotFound)
+			fmt.Fprint(w, `{"message": "not found"}`)
+		}
+	}))
+	defer server.Close()
+
+	hc, err := NewHTTPClientWithOptions(WithAddress(server.URL))
+	if err != nil {
+		t.Fatal(err)
+	}
+	stream := func(path string) *NDJSONStream[struct{ ID int }] {
+		resp, err := hc.GetWithContext(context.Background(), path, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		s, err := StreamNDJSON[struct{ ID int }](hc, resp)
+		if err != nil {
+			t.Fatal(err)
+		}
+		t.Cleanup(func() { s.Close() })
+		return s
+	}
+
+	records := stream("/records")
+	var ids []int
+	for records.Next() {
+		ids = append(ids, records.Item().ID)
+	}
+	if records.Err() != nil || fmt.Sprint(ids) != "[1 2 3]" {
+		t.Fatalf("expected blank lines are skipped, got: %v, %v", ids, records.Err())
+	}
+
+	invalid := stream("/invalid")
+	for invalid.Next() {
+	}
+	if err := invalid.Err(); err == nil || !strings.Contains(err.Error(), "line 2") {
+		t.Fatalf("expected decode error of line 2, got: %v", err)
+	}
+
+	resp, err := hc.GetWithContext(context.Background(), "/missing", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var httpErr *HTTPError
+	if _, err := StreamNDJSON[struct{ ID int }](hc, resp); !errors.As(err, &httpErr) || httpErr.Message != "not found" {
+		t.Fatalf("expected HTTPError of 404, got: %v", err)
+	}
+}
@@ -0,0 +1,363 @@
+package httpclient
+
+import (
+	"bytes"
+	"context"
+	jsonlib "encoding/json"
+	"errors"
+	"fmt"
+	"github.com/uddmorningsun/go-livingkit"
+	"io"
+	"net/http"
+	urllib "net/url"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// ErrMaxPagesReached is returned by Paginator.Err if there are more pages after PaginatorConfig.MaxPages.
+var ErrMaxPagesReached = errors.New("paginator reached max pages")
+
+// PageResult is the fetched page passed to PaginationStrategy.
+type PageResult struct {
+	// URL is the request URL of the page.
+	URL    *urllib.URL
+	Header http.Header
+	Body   []byte
+	// Count is the decoded items count of the page.
+	Count int
+}
+
+// PaginationStrategy decides URL of each page.
+type PaginationStrategy interface {
+	// First modifies URL of the first page, e.g.: set `page=1&size=20` query params.
+	First(u *urllib.URL)
+	// Next returns URL of the next page, nil means no more pages.
+	Next(page *PageResult) (*urllib.URL, error)
+}
+
+func withQuery(u *urllib.URL, values map[string]string) *urllib.URL {
+	next := *u
+	query := next.Query()
+	for key, value := range values {
+		query.Set(key, value)
+	}
+	next.RawQuery = query.Encode()
+	return &next
+}
+
+// PageNumberPagination paginates by page number and size params, it stops when a page has less items than Size.
+type PageNumberPagination struct {
+	// PageParam default is `page`.
+	PageParam string
+	// SizeParam default is `size`.
+	SizeParam string
+	// FirstPage default is 1.
+	FirstPage int
+	Size      int
+}
+
+func (p PageNumberPagination) params() (string, string, int) {
+	pageParam, sizeParam, firstPage := p.PageParam, p.SizeParam, p.FirstPage
+	if pageParam == "" {
+		pageParam = "page"
+	}
+	if sizeParam == "" {
+		sizeParam = "size"
+	}
+	if firstPage == 0 {
+		firstPage = 1
+	}
+	return pageParam, sizeParam, firstPage
+}
+
+func (p PageNumberPagination) First(u *urllib.URL) {
+	pageParam, sizeParam, firstPage := p.params()
+	*u = *withQuery(u, map[string]string{pageParam: strconv.Itoa(firstPage), sizeParam: strconv.Itoa(p.Size)})
+}
+
+func (p PageNumberPagination) Next(page *PageResult) (*urllib.URL, error) {
+	if page.Count == 0 || page.Count < p.Size {
+		return nil, nil
+	}
+	pageParam, _, _ := p.params()
+	current, err := strconv.Atoi(page.URL.Query().Get(pageParam))
+	if err != nil {
+		return nil, fmt.Errorf("invalid page param: %s, error: %s", pageParam, err)
+	}
+	return withQuery(page.URL, map[string]string{pageParam: strconv.Itoa(current + 1)}), nil
+}
+
+// OffsetPagination paginates by offset and limit params, it stops when a page has less items than Limit.
+type OffsetPagination struct {
+	// OffsetParam default is `offset`.
+	OffsetParam string
+	// LimitParam default is `limit`.
+	LimitParam string
+	Limit      int
+}
+
+func (p OffsetPagination) params() (string, string) {
+	offsetParam, limitParam := p.OffsetParam, p.LimitParam
+	if offsetParam == "" {
+		offsetParam = "offset"
+	}
+	if limitParam == "" {
+		limitParam = "limit"
+	}
+	return offsetParam, limitParam
+}
+
+func (p OffsetPagination) First(u *urllib.URL) {
+	offsetParam, limitParam := p.params()
+	*u = *withQuery(u, map[string]string{offsetParam: "0", limitParam: strconv.Itoa(p.Limit)})
+}
+
+func (p OffsetPagination) Next(page *PageResult) (*urllib.URL, error) {
+	if page.Count == 0 || page.Count < p.Limit {
+		return nil, nil
+	}
+	offsetParam, _ := p.params()
+	current, err := strconv.Atoi(page.URL.Query().Get(offsetParam))
+	if err != nil {
+		return nil, fmt.Errorf("invalid offset param: %s, error: %s", offsetParam, err)
+	}
+	return withQuery(page.URL, map[string]string{offsetParam: strconv.Itoa(current + page.Count)}), nil
+}
+
+// CursorPagination paginates by the cursor returned from top-level JSON field or header of the response, it stops when
+// the cursor is empty.
+type CursorPagination struct {
+	// CursorParam default is `cursor`.
+	CursorParam string
+	// CursorField is the top-level JSON field of response body, e.g.: `next_cursor`.
+	CursorField string
+	// CursorHeader is the response header, it's used if CursorField is empty.
+	CursorHeader string
+}
+
+func (p CursorPagination) First(*urllib.URL) {}
+
+func (p CursorPagination) Next(page *PageResult) (*urllib.URL, error) {
+	var cursor string
+	if p.CursorField != "" {
+		var fields map[string]jsonlib.RawMessage
+		if err := jsonlib.Unmarshal(page.Body, &fields); err != nil {
+			return nil, fmt.Errorf("unable to unmarshal cursor, error: %s", err)
+		}
+		if raw, ok := fields[p.CursorField]; ok && string(raw) != "null" {
+			// Numeric cursor is kept as it is, e.g.: 12345678 rather than 1.2345678e+07 of float64.
+			decoder := jsonlib.NewDecoder(bytes.NewReader(raw))
+			decoder.UseNumber()
+			var value interface{}
+			if err := decoder.Decode(&value); err != nil {
+				return nil, fmt.Errorf("unable to unmarshal cursor, error: %s", err)
+			}
+			cursor = fmt.Sprint(value)
+		}
+	} else {
+		cursor = page.Header.Get(p.CursorHeader)
+	}
+	if cursor == "" {
+		return nil, nil
+	}
+	cursorParam := p.CursorParam
+	if cursorParam == "" {
+		cursorParam = "cursor"
+	}
+	return withQuery(page.URL, map[string]string{cursorParam: cursor}), nil
+}
+
+var linkNextRE = regexp.MustCompile(`<([^>]*)>\s*;[^,]*rel="?([^",]*\bnext\b[^",]*)"?`)
+
+// LinkHeaderPagination follows `Link: <url>; rel="next"` response header, see: https://datatracker.ietf.org/doc/html/rfc8288
+type LinkHeaderPagination struct{}
+
+func (p LinkHeaderPagination) First(*urllib.URL) {}
+
+func (p LinkHeaderPagination) Next(page *PageResult) (*urllib.URL, error) {
+	for _, value := range page.Header.Values("Link") {
+		for _, link := range strings.Split(value, ",") {
+			matches := linkNextRE.FindStringSubmatch(link)
+			if matches == nil {
+				continue
+			}
+			next, err := page.URL.Parse(matches[1])
+			if err != nil {
+				return nil, fmt.Errorf("invalid next link: %s, error: %s", matches[1], err)
+			}
+			return next, nil
+		}
+	}
+	return nil, nil
+}
+
+// PaginatorConfig configures a Paginator.
+type PaginatorConfig struct {
+	Strategy PaginationStrategy
+	// Params are URL query params of every page.
+	Params urllib.Values
+	// ItemsField is the top-level JSON field of items, e.g.: `data`, empty means response body is the items list which
+	// is decoded by the decoder of response Content-Type.
+	ItemsField string
+	// MaxPages is the safety limit of pages, zero means no limit.
+	MaxPages int
+	Options  []RequestOption
+}
+
+// Paginator iterates over items of a paginated API lazily, the next page is fetched when items of current page are
+// consumed. E.g.:
+//
+//	paginator := httpclient.NewPaginator[User](hc, "/v1/users", httpclient.PaginatorConfig{
+//		Strategy: httpclient.PageNumberPagination{Size: 100},
+//		MaxPages: 1000,
+//	})
+//	for paginator.Next(ctx) {
+//		user := paginator.Item()
+//	}
+//	if err := paginator.Err(); err != nil {
+//		return err
+//	}
+type Paginator[T any] struct {
+	hc    *HTTPClient
+	path  string
+	cfg   PaginatorConfig
+	next  *urllib.URL
+	pages int
+	items []T
+	index int
+	item  T
+	err   error
+	done  bool
+}
+
+// NewPaginator returns a Paginator of path which is resolved against HTTPClient.Address.
+func NewPaginator[T any](hc *HTTPClient, path string, cfg PaginatorConfig) *Paginator[T] {
+	return &Paginator[T]{hc: hc, path: path, cfg: cfg}
+}
+
+// Next advances to the next item, it returns false when there are no more items, ctx is done or error happens.
+func (p *Paginator[T]) Next(ctx context.Context) bool {
+	if p.err != nil {
+		return false
+	}
+	for p.index >= len(p.items) {
+		if p.done {
+			return false
+		}
+		if err := ctx.Err(); err != nil {
+			p.err = err
+			return false
+		}
+		if err := p.fetch(ctx); err != nil {
+			p.err = err
+			return false
+		}
+	}
+	p.item = p.items[p.index]
+	p.index++
+	return true
+}
+
+// Item returns the current item.
+func (p *Paginator[T]) Item() T {
+	return p.item
+}
+
+// Err returns the error which stops iteration.
+func (p *Paginator[T]) Err() error {
+	return p.err
+}
+
+// All consumes the remaining items.
+func (p *Paginator[T]) All(ctx context.Context) ([]T, error) {
+	var items []T
+	for p.Next(ctx) {
+		items = append(items, p.Item())
+	}
+	return items, p.Err()
+}
+
+func (p *Paginator[T]) fetch(ctx context.Context) error {
+	if p.cfg.Strategy == nil {
+		return fmt.Errorf("required pagination strategy")
+	}
+	if p.next == nil {
+		first, err := p.firstURL()
+		if err != nil {
+			return err
+		}
+		p.next = first
+	}
+	if p.cfg.MaxPages > 0 && p.pages >= p.cfg.MaxPages {
+		return ErrMaxPagesReached
+	}
+
+	current := p.next
+	resp, err := p.hc.GetWithContext(ctx, current.String(), nil, p.cfg.Options...)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return newHTTPError(resp, body, fmt.Errorf("unable to read response, error: %w", err))
+	}
+	if !p.hc.OK(resp) {
+		httpErr := newHTTPError(resp, body, nil)
+		httpErr.parseBody()
+		return httpErr
+	}
+	items, err := p.decode(resp, body)
+	if err != nil {
+		return newHTTPError(resp, body, fmt.Errorf("unable to decode page, error: %w", err))
+	}
+	p.pages++
+	p.items, p.index = items, 0
+
+	next, err := p.cfg.Strategy.Next(&PageResult{URL: current, Header: resp.Header, Body: body, Count: len(items)})
+	if err != nil {
+		return err
+	}
+	p.next = next
+	p.done = next == nil
+	return nil
+}
+
+func (p *Paginator[T]) firstURL() (*urllib.URL, error) {
+	path, err := p.hc.PreparePath(p.path, p.cfg.Params)
+	if err != nil {
+		return nil, err
+	}
+	resolved, err := p.hc.ResolveURL(path)
+	if err != nil {
+		return nil, err
+	}
+	u, err := urllib.Parse(resolved)
+	if err != nil {
+		return nil, fmt.Errorf("unable to parse path: %s, error: %s", resolved, err)
+	}
+	p.cfg.Strategy.First(u)
+	return u, nil
+}
+
+func (p *Paginator[T]) decode(resp *http.Response, body []byte) ([]T, error) {
+	var items []T
+	if len(body) == 0 {
+		return items, nil
+	}
+	if p.cfg.ItemsField == "" {
+		err := p.hc.decoder(resp.Header.Get(livingkit.ContentType)).Decode(body, &items)
+		return items, err
+	}
+	var fields map[string]jsonlib.RawMessage
+	if err := jsonlib.Unmarshal(body, &fields); err != nil {
+		return nil, err
+	}
+	raw, ok := fields[p.cfg.ItemsField]
+	if !ok || string(raw) == "null" {
+		return items, nil
+	}
+	err := jsonlib.Unmarshal(raw, &items)
+	return items, err
+}
@@ -0,0 +1,42 @@
+package httpclient
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestCursorPaginationNumericCursor(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		switch cursor := req.URL.Query().Get("cursor"); cursor {
+		case "":
+			fmt.Fprint(w, `{"data": [1, 2], "next_cursor": 12345678}`)
+		case "12345678":
+			fmt.Fprint(w, `{"data": [3], "next_cursor": "abc"}`)
+		case "abc":
+			fmt.Fprint(w, `{"data": [4], "next_cursor": null}`)
+		default:
+			http.Error(w, "unexpected cursor: "+cursor, http.StatusBadRequest)
+		}
+	}))
+	defer server.Close()
+
+	hc, err := NewHTTPClientWithOptions(WithAddress(server.URL))
+	if err != nil {
+		t.Fatal(err)
+	}
+	items, err := NewPaginator[int](hc, "/items", PaginatorConfig{
+		Strategy:   CursorPagination{CursorField: "next_cursor"},
+		ItemsField: "data",
+		MaxPages:   10,
+	}).All(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if fmt.Sprint(items) != "[1 2 3 4]" {
+		t.Fatalf("expected items of all pages, got: %v", items)
+	}
+}
@@ -0,0 +1,121 @@
+package httpclient
+
+import (
+	jsonlib "encoding/json"
+	"github.com/uddmorningsun/go-livingkit"
+	"mime"
+	"net/http"
+	urllib "net/url"
+	"strings"
+)
+
+const redactedValue = "[REDACTED]"
+
+var (
+	// DefaultRedactHeaders are always redacted by Redactor.
+	DefaultRedactHeaders = []string{"Authorization", "Proxy-Authorization", "Cookie", "Set-Cookie", "X-Api-Key"}
+	// DefaultRedactFields are always redacted in JSON body, form body and URL query params by Redactor.
+	DefaultRedactFields = []string{"password", "secret", "token", "access_token", "refresh_token", "client_secret"}
+)
+
+// Redactor replaces values of sensitive headers and fields with `[REDACTED]`, it's shared by WithDebug and
+// cassette.Recorder so that logs and recorded cassettes are redacted the same way.
+type Redactor struct {
+	headers map[string]struct{}
+	fields  map[string]struct{}
+}
+
+// NewRedactor returns a Redactor of headers and fields in addition to DefaultRedactHeaders and DefaultRedactFields,
+// both are case-insensitive.
+func NewRedactor(headers, fields []string) *Redactor {
+	r := &Redactor{headers: make(map[string]struct{}), fields: make(map[string]struct{})}
+	for _, names := range [][]string{DefaultRedactHeaders, headers} {
+		for _, header := range names {
+			r.headers[http.CanonicalHeaderKey(header)] = struct{}{}
+		}
+	}
+	for _, names := range [][]string{DefaultRedactFields, fields} {
+		for _, field := range names {
+			r.fields[strings.ToLower(field)] = struct{}{}
+		}
+	}
+	return r
+}
+
+// URL returns the URL with redacted query params, u is not modified.
+func (r *Redactor) URL(u *urllib.URL) string {
+	if u.RawQuery == "" {
+		return u.String()
+	}
+	redacted := *u
+	redacted.RawQuery = r.Values(u.Query()).Encode()
+	return redacted.String()
+}
+
+// Header returns a redacted copy of header.
+func (r *Redactor) Header(header http.Header) http.Header {
+	redacted := header.Clone()
+	for key := range redacted {
+		if _, ok := r.headers[http.CanonicalHeaderKey(key)]; ok {
+			redacted[key] = []string{redactedValue}
+		}
+	}
+	return redacted
+}
+
+// Values redacts values in place and returns it.
+func (r *Redactor) Values(values urllib.Values) urllib.Values {
+	for key := range values {
+		if _, ok := r.fields[strings.ToLower(key)]; ok {
+			values[key] = []string{redactedValue}
+		}
+	}
+	return values
+}
+
+func (r *Redactor) redactJSON(value interface{}) interface{} {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		for key, item := range v {
+			if _, ok := r.fields[strings.ToLower(key)]; ok {
+				v[key] = redactedValue
+				continue
+			}
+			v[key] = r.redactJSON(item)
+		}
+	case []interface{}:
+		for i, item := range v {
+			v[i] = r.redactJSON(item)
+		}
+	}
+	return value
+}
+
+// redactableBody reports whether fields of the body with content type are redacted by Redactor.Body.
+func redactableBody(contentType string) bool {
+	mediaType, _, _ := mime.ParseMediaType(contentType)
+	return isJSONMediaType(mediaType) || mediaType == livingkit.ApplicationXWWWFormUrlencoded
+}
+
+func isJSONMediaType(mediaType string) bool {
+	return mediaType == livingkit.ApplicationJSON || strings.HasSuffix(mediaType, "+json")
+}
+
+// Body redacts JSON or form body, the body which can't be parsed or has other content type is returned as is.
+func (r *Redactor) Body(contentType string, body []byte) []byte {
+	mediaType, _, _ := mime.ParseMediaType(contentType)
+	switch {
+	case isJSONMediaType(mediaType):
+		var value interface{}
+		if err := jsonlib.Unmarshal(body, &value); err == nil {
+			if redacted, err := jsonlib.Marshal(r.redactJSON(value)); err == nil {
+				return redacted
+			}
+		}
+	case mediaType == livingkit.ApplicationXWWWFormUrlencoded:
+		if values, err := urllib.ParseQuery(string(body)); err == nil {
+			return []byte(r.Values(values).Encode())
+		}
+	}
+	return body
+}
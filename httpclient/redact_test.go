@@ -0,0 +1,43 @@
+package httpclient
+
+import (
+	"github.com/uddmorningsun/go-livingkit"
+	"net/http"
+	urllib "net/url"
+	"testing"
+)
+
+func TestRedactor(t *testing.T) {
+	redactor := NewRedactor([]string{"x-tenant"}, []string{"Account"})
+
+	header := http.Header{"Authorization": {"Bearer secret"}, "X-Tenant": {"acme"}, "Accept": {"*/*"}}
+	redacted := redactor.Header(header)
+	if redacted.Get("Authorization") != redactedValue || redacted.Get("X-Tenant") != redactedValue || redacted.Get("Accept") != "*/*" {
+		t.Fatalf("unexpected redacted header: %v", redacted)
+	}
+	if header.Get("Authorization") != "Bearer secret" {
+		t.Fatalf("expected original header is not modified, got: %v", header)
+	}
+
+	u, _ := urllib.Parse("https://api.example.com/v1?token=abc&page=2")
+	if actual, expected := redactor.URL(u), "https://api.example.com/v1?page=2&token=%5BREDACTED%5D"; actual != expected {
+		t.Fatalf("expected redacted URL: %s, got: %s", expected, actual)
+	}
+
+	cases := []struct {
+		contentType, body, expected string
+	}{
+		{
+			contentType: livingkit.ApplicationJSON,
+			body:        `{"user":{"password":"p","name":"n"},"items":[{"ACCOUNT":1}]}`,
+			expected:    `{"items":[{"ACCOUNT":"[REDACTED]"}],"user":{"name":"n","password":"[REDACTED]"}}`,
+		},
+		{contentType: livingkit.ApplicationXWWWFormUrlencoded, body: "secret=s&name=n", expected: "name=n&secret=%5BREDACTED%5D"},
+		{contentType: "text/plain", body: "password=p", expected: "password=p"},
+	}
+	for _, c := range cases {
+		if actual := string(redactor.Body(c.contentType, []byte(c.body))); actual != c.expected {
+			t.Fatalf("expected redacted %s body: %s, got: %s", c.contentType, c.expected, actual)
+		}
+	}
+}
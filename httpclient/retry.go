@@ -0,0 +1,209 @@
+package httpclient
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"github.com/sirupsen/logrus"
+	"github.com/uddmorningsun/go-livingkit"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	headerRetryAfter     = "Retry-After"
+	headerIdempotencyKey = "Idempotency-Key"
+)
+
+var (
+	// DefaultRetryStatusCodes will be retried if no status code is given to WithRetry.
+	DefaultRetryStatusCodes = []int{
+		http.StatusTooManyRequests,
+		http.StatusBadGateway,
+		http.StatusServiceUnavailable,
+		http.StatusGatewayTimeout,
+	}
+	// idempotentMethods are safe to be sent more than once, see: https://datatracker.ietf.org/doc/html/rfc7231#section-4.2.2
+	idempotentMethods = map[string]struct{}{
+		http.MethodGet:     {},
+		http.MethodHead:    {},
+		http.MethodOptions: {},
+		http.MethodTrace:   {},
+		http.MethodPut:     {},
+		http.MethodDelete:  {},
+	}
+)
+
+type retryConfig struct {
+	policy      livingkit.RetryPolicy
+	statusCodes map[int]struct{}
+}
+
+// WithRetry enables automatic retries with the given policy for idempotent methods, and also for POST/PATCH request
+// which carries an `Idempotency-Key` header. Transport errors and response with the given status codes (default is
+// DefaultRetryStatusCodes) will be retried, the `Retry-After` response header is honored, and the last response is
+// returned without retrying if it asks to wait longer than MaxInterval of the policy.
+// Request body will be buffered once if it can't be rebuilt by http.Request.GetBody.
+// The policy should be bounded by MaxAttempts or MaxElapsedTime, and unlimited attempts require InitialInterval, so that
+// a dead host is not hammered without delay.
+func WithRetry(policy livingkit.RetryPolicy, statusCodes ...int) Option {
+	return func(hc *HTTPClient) error {
+		if policy.MaxAttempts <= 0 && policy.MaxElapsedTime <= 0 {
+			return fmt.Errorf("invalid retry policy, 'MaxAttempts' or 'MaxElapsedTime' should be greater than 0")
+		}
+		if policy.MaxAttempts <= 0 && policy.InitialInterval <= 0 {
+			return fmt.Errorf("invalid retry policy, 'InitialInterval' should be greater than 0 if 'MaxAttempts' is unlimited")
+		}
+		if len(statusCodes) == 0 {
+			statusCodes = DefaultRetryStatusCodes
+		}
+		rc := &retryConfig{policy: policy, statusCodes: make(map[int]struct{}, len(statusCodes))}
+		for _, code := range statusCodes {
+			if code < 100 || code > 599 {
+				return fmt.Errorf("invalid retry status code: %d", code)
+			}
+			rc.statusCodes[code] = struct{}{}
+		}
+		hc.retry = rc
+		return nil
+	}
+}
+
+func (rc *retryConfig) retryableRequest(req *http.Request) bool {
+	if _, ok := idempotentMethods[req.Method]; ok {
+		return true
+	}
+	return req.Header.Get(headerIdempotencyKey) != ""
+}
+
+// do sends request with retry, if all attempts end with retryable status code, the last response will be returned.
+func (rc *retryConfig) do(doFunc func(*http.Request) (*http.Response, error), req *http.Request) (*http.Response, error) {
+	if err := rewindableBody(req); err != nil {
+		return nil, err
+	}
+	// Attempt timeout is applied here, since context of the attempt must live until response body is closed.
+	policy := rc.policy
+	attemptTimeout := policy.AttemptTimeout
+	policy.AttemptTimeout = 0
+
+	var (
+		resp    *http.Response
+		attempt int
+	)
+	err := policy.Do(req.Context(), func(ctx context.Context) error {
+		attempt++
+		if resp != nil {
+			drainBody(resp)
+			resp = nil
+		}
+		attemptReq, cancel, err := newAttemptRequest(ctx, req, attempt, attemptTimeout)
+		if err != nil {
+			return livingkit.Permanent(err)
+		}
+		response, err := doFunc(attemptReq)
+		if err != nil {
+			cancel()
+			logrus.Debugf("request (%s:%s) attempt %d failed, error: %s", req.URL, req.Method, attempt, err)
+			// Open circuit breaker should fail fast rather than waiting for it.
+			if errors.Is(err, ErrCircuitOpen) {
+				return livingkit.Permanent(err)
+			}
+			return err
+		}
+		response.Body = &cancelOnCloseBody{ReadCloser: response.Body, cancel: cancel}
+		resp = response
+		if _, ok := rc.statusCodes[response.StatusCode]; !ok {
+			return nil
+		}
+		statusErr := fmt.Errorf("request (%s:%s) got retryable status: %s", req.URL, req.Method, response.Status)
+		if delay, ok := parseRetryAfter(response.Header.Get(headerRetryAfter)); ok {
+			return livingkit.RetryAfter(statusErr, delay)
+		}
+		return statusErr
+	})
+	if resp != nil {
+		if err != nil && req.Context().Err() != nil {
+			drainBody(resp)
+			return nil, err
+		}
+		return resp, nil
+	}
+	return nil, err
+}
+
+// rewindableBody makes sure the request body can be rebuilt by http.Request.GetBody for each attempt.
+func rewindableBody(req *http.Request) error {
+	if req.Body == nil || req.Body == http.NoBody || req.GetBody != nil {
+		return nil
+	}
+	payload, err := io.ReadAll(req.Body)
+	_ = req.Body.Close()
+	if err != nil {
+		return fmt.Errorf("unable to buffer request body for retry, error: %s", err)
+	}
+	req.GetBody = func() (io.ReadCloser, error) {
+		return io.NopCloser(bytes.NewReader(payload)), nil
+	}
+	req.Body, _ = req.GetBody()
+	return nil
+}
+
+func newAttemptRequest(ctx context.Context, req *http.Request, attempt int, timeout time.Duration) (*http.Request, context.CancelFunc, error) {
+	cancel := context.CancelFunc(func() {})
+	if timeout > 0 {
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+	}
+	attemptReq := req.Clone(ctx)
+	if attempt > 1 && req.GetBody != nil {
+		body, err := req.GetBody()
+		if err != nil {
+			cancel()
+			return nil, nil, fmt.Errorf("unable to rebuild request body, error: %s", err)
+		}
+		attemptReq.Body = body
+	}
+	return attemptReq, cancel, nil
+}
+
+// parseRetryAfter parses `Retry-After` header which is delay-seconds or HTTP-date,
+// see: https://datatracker.ietf.org/doc/html/rfc7231#section-7.1.3
+func parseRetryAfter(value string) (time.Duration, bool) {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return 0, false
+	}
+	if seconds, err := strconv.Atoi(value); err == nil {
+		if seconds < 0 {
+			return 0, false
+		}
+		return time.Duration(seconds) * time.Second, true
+	}
+	if date, err := http.ParseTime(value); err == nil {
+		delay := time.Until(date)
+		if delay < 0 {
+			delay = 0
+		}
+		return delay, true
+	}
+	return 0, false
+}
+
+// drainBody reads and closes the response body, so that the underlying connection can be reused.
+func drainBody(resp *http.Response) {
+	_, _ = io.Copy(io.Discard, io.LimitReader(resp.Body, 4096))
+	_ = resp.Body.Close()
+}
+
+type cancelOnCloseBody struct {
+	io.ReadCloser
+	cancel context.CancelFunc
+}
+
+func (b *cancelOnCloseBody) Close() error {
+	defer b.cancel()
+	return b.ReadCloser.Close()
+}
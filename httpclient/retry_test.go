@@ -0,0 +1,92 @@
+package httpclient
+
+import (
+	"context"
+	"github.com/uddmorningsun/go-livingkit"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestWithRetryValidatesPolicy(t *testing.T) {
+	cases := []struct {
+		name   string
+		policy livingkit.RetryPolicy
+		valid  bool
+	}{
+		{name: "zero value", policy: livingkit.RetryPolicy{}},
+		{name: "unlimited attempts without delay", policy: livingkit.RetryPolicy{MaxElapsedTime: time.Second}},
+		{name: "max attempts", policy: livingkit.RetryPolicy{MaxAttempts: 3}, valid: true},
+		{
+			name:   "max elapsed time",
+			policy: livingkit.RetryPolicy{MaxElapsedTime: time.Second, InitialInterval: time.Millisecond},
+			valid:  true,
+		},
+		{name: "default", policy: livingkit.DefaultRetryPolicy(), valid: true},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			_, err := NewHTTPClientWithOptions(WithRetry(c.policy))
+			if c.valid != (err == nil) {
+				t.Fatalf("expected valid: %t, got error: %v", c.valid, err)
+			}
+		})
+	}
+}
+
+func TestWithRetryRetriesStatusCode(t *testing.T) {
+	var calls int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		if atomic.AddInt32(&calls, 1) == 1 {
+			w.Header().Set(headerRetryAfter, "0")
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	hc, err := NewHTTPClientWithOptions(WithAddress(server.URL), WithRetry(livingkit.RetryPolicy{MaxAttempts: 3}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp, err := hc.GetWithContext(context.Background(), "/", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK || atomic.LoadInt32(&calls) != 2 {
+		t.Fatalf("expected 200 after 2 calls, got: %d after %d calls", resp.StatusCode, calls)
+	}
+}
+
+func TestWithRetryRetryAfterOverMaxInterval(t *testing.T) {
+	var calls int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		atomic.AddInt32(&calls, 1)
+		w.Header().Set(headerRetryAfter, "86400")
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer server.Close()
+
+	hc, err := NewHTTPClientWithOptions(
+		WithAddress(server.URL), WithRetry(livingkit.RetryPolicy{MaxAttempts: 3, MaxInterval: time.Second}),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	startedTime := time.Now()
+	resp, err := hc.GetWithContext(context.Background(), "/", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusServiceUnavailable || atomic.LoadInt32(&calls) != 1 {
+		t.Fatalf("expected the last 503 without retry, got: %d after %d calls", resp.StatusCode, calls)
+	}
+	if elapsed := time.Since(startedTime); elapsed > time.Second {
+		t.Fatalf("expected no sleep over MaxInterval, got: %s", elapsed)
+	}
+}
@@ -0,0 +1,302 @@
+package httpclient
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"github.com/uddmorningsun/go-livingkit"
+	"io"
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	defaultReconnectDelay = 3 * time.Second
+	// maxReconnectDelay caps the backoff of consecutive reconnects, unless server asks for a longer reconnection time.
+	maxReconnectDelay = 30 * time.Second
+)
+
+// Event is a Server-Sent Event, see: https://html.spec.whatwg.org/multipage/server-sent-events.html
+type Event struct {
+	ID string
+	// Event is the event type, default is `message`.
+	Event string
+	Data  string
+	// Retry is the reconnection time sent by server, zero means not set.
+	Retry time.Duration
+}
+
+// EventStreamConfig configures an EventStream.
+type EventStreamConfig struct {
+	// LastEventID is sent with `Last-Event-ID` header of the first connection.
+	LastEventID string
+	// ReconnectDelay is the delay before reconnecting, default is 3s, it's overridden by `retry` field of the stream.
+	// The delay is doubled for each consecutive reconnect without receiving any event, e.g.: server responds 5xx.
+	ReconnectDelay time.Duration
+	// MaxReconnects limits consecutive reconnects without receiving any event, zero means no limit, negative disables
+	// reconnect.
+	MaxReconnects int
+	Options       []RequestOption
+}
+
+// EventStream reads Server-Sent Events of a GET request, it reconnects with `Last-Event-ID` header when the connection
+// is closed or broken. The whole stream is bounded by ctx rather than WithTimeout, which also applies to every
+// connection. E.g.:
+//
+//	stream := hc.Events(ctx, "/v1/events", httpclient.EventStreamConfig{})
+//	defer stream.Close()
+//	for stream.Next() {
+//		event := stream.Event()
+//	}
+//	if err := stream.Err(); err != nil {
+//		return err
+//	}
+type EventStream struct {
+	hc          *HTTPClient
+	ctx         context.Context
+	path        string
+	cfg         EventStreamConfig
+	lastEventID string
+	delay       time.Duration
+	reconnects  int
+	reader      *bufio.Reader
+	event       Event
+	err         error
+
+	// mu guards body and closed, since Close is usually called by another goroutine to stop Next.
+	mu     sync.Mutex
+	body   io.ReadCloser
+	closed bool
+	// done is closed by Close to interrupt reconnection wait.
+	done chan struct{}
+}
+
+// Events returns an EventStream of path, the connection is established on the first EventStream.Next.
+func (hc *HTTPClient) Events(ctx context.Context, path string, cfg EventStreamConfig) *EventStream {
+	delay := cfg.ReconnectDelay
+	if delay <= 0 {
+		delay = defaultReconnectDelay
+	}
+	return &EventStream{
+		hc:          hc,
+		ctx:         ctx,
+		path:        path,
+		cfg:         cfg,
+		lastEventID: cfg.LastEventID,
+		delay:       delay,
+		done:        make(chan struct{}),
+	}
+}
+
+// Next reads the next event, it returns false when the stream is closed, ctx is done or error happens.
+func (s *EventStream) Next() bool {
+	for s.err == nil && !s.isClosed() {
+		if s.reader == nil {
+			if retryable, err := s.connect(); err != nil {
+				if !retryable {
+					s.err = err
+					return false
+				}
+				s.reconnect(err)
+				continue
+			}
+			if s.isClosed() {
+				return false
+			}
+		}
+		event, err := s.readEvent()
+		if err == nil {
+			s.event = event
+			s.reconnects = 0
+			return true
+		}
+		s.disconnect()
+		s.reconnect(err)
+	}
+	return false
+}
+
+// Event returns the current event.
+func (s *EventStream) Event() Event {
+	return s.event
+}
+
+// LastEventID returns ID of the last event, it's sent with `Last-Event-ID` header when reconnecting.
+func (s *EventStream) LastEventID() string {
+	return s.lastEventID
+}
+
+// Err returns the error which stops the stream.
+func (s *EventStream) Err() error {
+	return s.err
+}
+
+// Close closes the connection and stops the stream, it's safe to be called by another goroutine while Next is blocked.
+func (s *EventStream) Close() error {
+	s.mu.Lock()
+	if !s.closed {
+		s.closed = true
+		close(s.done)
+	}
+	s.mu.Unlock()
+	s.disconnect()
+	return nil
+}
+
+func (s *EventStream) isClosed() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.closed
+}
+
+// connect requests the stream, the returned error is not retryable if server rejects the request except 5xx.
+func (s *EventStream) connect() (bool, error) {
+	opts := append([]RequestOption{func(req *http.Request) error {
+		req.Header.Set("Accept", livingkit.TextEventStream)
+		req.Header.Set("Cache-Control", "no-cache")
+		if s.lastEventID != "" {
+			req.Header.Set("Last-Event-ID", s.lastEventID)
+		}
+		return nil
+	}}, s.cfg.Options...)
+	resp, err := s.hc.DoRequestWithContext(s.ctx, http.MethodGet, s.path, nil, opts...)
+	if err != nil {
+		return true, err
+	}
+	// 204 tells client to stop reconnecting.
+	if resp.StatusCode == http.StatusNoContent {
+		drainBody(resp)
+		return false, s.Close()
+	}
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, maxErrorMessageBody))
+		resp.Body.Close()
+		httpErr := newHTTPError(resp, body, nil)
+		httpErr.parseBody()
+		return resp.StatusCode >= http.StatusInternalServerError, httpErr
+	}
+	if mediaType, _, _ := mime.ParseMediaType(resp.Header.Get(livingkit.ContentType)); mediaType != livingkit.TextEventStream {
+		drainBody(resp)
+		return false, newHTTPError(resp, nil, fmt.Errorf("unexpected content type: %s", mediaType))
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.closed {
+		resp.Body.Close()
+		return false, nil
+	}
+	s.body, s.reader = resp.Body, bufio.NewReader(resp.Body)
+	return false, nil
+}
+
+// disconnect closes the body, the blocked read of Next returns error then.
+func (s *EventStream) disconnect() {
+	s.mu.Lock()
+	body := s.body
+	s.body = nil
+	s.mu.Unlock()
+	if body != nil {
+		body.Close()
+	}
+}
+
+// reconnect waits for the reconnection time, or stops the stream if err is not recoverable.
+func (s *EventStream) reconnect(err error) {
+	s.reader = nil
+	switch {
+	case s.isClosed():
+		return
+	case s.ctx.Err() != nil:
+		s.err = s.ctx.Err()
+		return
+	case s.cfg.MaxReconnects < 0 || (s.cfg.MaxReconnects > 0 && s.reconnects >= s.cfg.MaxReconnects):
+		s.err = fmt.Errorf("event stream disconnected after %d reconnects, error: %w", s.reconnects, err)
+		return
+	}
+	timer := time.NewTimer(s.reconnectDelay())
+	defer timer.Stop()
+	s.reconnects++
+	select {
+	case <-s.ctx.Done():
+		s.err = s.ctx.Err()
+	case <-s.done:
+	case <-timer.C:
+	}
+}
+
+// reconnectDelay doubles the reconnection time for each consecutive reconnect, up to maxReconnectDelay or the
+// reconnection time sent by server if it's longer.
+func (s *EventStream) reconnectDelay() time.Duration {
+	limit := maxReconnectDelay
+	if s.delay > limit {
+		limit = s.delay
+	}
+	delay := s.delay
+	for i := 0; i < s.reconnects && delay < limit; i++ {
+		delay *= 2
+	}
+	if delay > limit {
+		return limit
+	}
+	return delay
+}
+
+// readEvent reads lines until an event is dispatched by a blank line.
+func (s *EventStream) readEvent() (Event, error) {
+	var (
+		event   Event
+		data    strings.Builder
+		hasData bool
+	)
+	for {
+		line, err := s.reader.ReadString('\n')
+		if err != nil {
+			// Incomplete event at the end of stream is discarded.
+			if err == io.EOF {
+				err = io.ErrUnexpectedEOF
+			}
+			return Event{}, err
+		}
+		line = strings.TrimSuffix(strings.TrimSuffix(line, "\n"), "\r")
+		if line == "" {
+			if !hasData {
+				event = Event{}
+				continue
+			}
+			event.ID = s.lastEventID
+			event.Data = strings.TrimSuffix(data.String(), "\n")
+			if event.Event == "" {
+				event.Event = "message"
+			}
+			return event, nil
+		}
+		if strings.HasPrefix(line, ":") {
+			continue
+		}
+		field, value := line, ""
+		if index := strings.IndexByte(line, ':'); index >= 0 {
+			field, value = line[:index], strings.TrimPrefix(line[index+1:], " ")
+		}
+		switch field {
+		case "event":
+			event.Event = value
+		case "data":
+			data.WriteString(value)
+			data.WriteByte('\n')
+			hasData = true
+		case "id":
+			if !strings.ContainsRune(value, 0) {
+				s.lastEventID = value
+			}
+		case "retry":
+			if milliseconds, err := strconv.ParseUint(value, 10, 63); err == nil {
+				event.Retry = time.Duration(milliseconds) * time.Millisecond
+				s.delay = event.Retry
+			}
+		}
+	}
+}
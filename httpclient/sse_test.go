@@ -0,0 +1,159 @@
+package httpclient
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"github.com/uddmorningsun/go-livingkit"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestEventStreamReadEvent(t *testing.T) {
+	input := ": comment\r\n" +
+		"id: 1\r\n" +
+		"event: update\r\n" +
+		"data: line1\r\n" +
+		"data: line2\r\n" +
+		"\r\n" +
+		"retry: 1500\n" +
+		"data:no-space\n" +
+		"\n" +
+		"\n" +
+		"id\n" +
+		"data: {\"a\": 1}\n" +
+		"\n" +
+		"data: incomplete"
+	s := &EventStream{reader: bufio.NewReader(strings.NewReader(input)), delay: time.Second}
+	expected := []Event{
+		{ID: "1", Event: "update", Data: "line1\nline2"},
+		{ID: "1", Event: "message", Data: "no-space", Retry: 1500 * time.Millisecond},
+		{ID: "", Event: "message", Data: `{"a": 1}`},
+	}
+	for i, want := range expected {
+		event, err := s.readEvent()
+		if err != nil || event != want {
+			t.Fatalf("expected event %d: %+v, got: %+v, %v", i, want, event, err)
+		}
+	}
+	if s.delay != 1500*time.Millisecond {
+		t.Fatalf("expected reconnection time set by retry field, got: %s", s.delay)
+	}
+	if _, err := s.readEvent(); !errors.Is(err, io.ErrUnexpectedEOF) {
+		t.Fatalf("expected incomplete event is discarded, got: %v", err)
+	}
+}
+
+func TestEventStreamReconnectDelay(t *testing.T) {
+	cases := []struct {
+		delay      time.Duration
+		reconnects int
+		expected   time.Duration
+	}{
+		{delay: time.Second, reconnects: 0, expected: time.Second},
+		{delay: time.Second, reconnects: 2, expected: 4 * time.Second},
+		{delay: time.Second, reconnects: 10, expected: maxReconnectDelay},
+		{delay: time.Minute, reconnects: 3, expected: time.Minute},
+	}
+	for _, c := range cases {
+		s := &EventStream{delay: c.delay, reconnects: c.reconnects}
+		if actual := s.reconnectDelay(); actual != c.expected {
+			t.Fatalf("expected delay %s of %d reconnects, got: %s", c.expected, c.reconnects, actual)
+		}
+	}
+}
+
+func TestEventStreamReconnects(t *testing.T) {
+	var calls int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		switch atomic.AddInt32(&calls, 1) {
+		case 1:
+			w.WriteHeader(http.StatusServiceUnavailable)
+		case 2:
+			w.Header().Set(livingkit.ContentType, livingkit.TextEventStream)
+			fmt.Fprint(w, "id: 7\ndata: ok\n\n")
+		default:
+			if req.Header.Get("Last-Event-ID") != "7" {
+				w.WriteHeader(http.StatusBadRequest)
+				return
+			}
+			w.WriteHeader(http.StatusNoContent)
+		}
+	}))
+	defer server.Close()
+
+	hc, err := NewHTTPClientWithOptions(WithAddress(server.URL))
+	if err != nil {
+		t.Fatal(err)
+	}
+	stream := hc.Events(context.Background(), "/", EventStreamConfig{ReconnectDelay: 10 * time.Millisecond})
+	defer stream.Close()
+	var events []string
+	for stream.Next() {
+		events = append(events, stream.Event().Data)
+	}
+	if err := stream.Err(); err != nil || len(events) != 1 || events[0] != "ok" || atomic.LoadInt32(&calls) != 3 {
+		t.Fatalf("expected 1 event after reconnecting from 503, got: %v, %v after %d calls", events, err, calls)
+	}
+
+	stream = hc.Events(context.Background(), "/", EventStreamConfig{ReconnectDelay: 10 * time.Millisecond})
+	defer stream.Close()
+	atomic.StoreInt32(&calls, 3)
+	var httpErr *HTTPError
+	if stream.Next() || !errors.As(stream.Err(), &httpErr) || httpErr.StatusCode != http.StatusBadRequest {
+		t.Fatalf("expected 4xx stops the stream, got: %v", stream.Err())
+	}
+}
+
+func TestEventStreamCloseFromAnotherGoroutine(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		w.Header().Set(livingkit.ContentType, livingkit.TextEventStream)
+		ticker := time.NewTicker(5 * time.Millisecond)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-req.Context().Done():
+				return
+			case <-ticker.C:
+				fmt.Fprint(w, "data: tick\n\n")
+				w.(http.Flusher).Flush()
+			}
+		}
+	}))
+	defer server.Close()
+
+	hc, err := NewHTTPClientWithOptions(WithAddress(server.URL))
+	if err != nil {
+		t.Fatal(err)
+	}
+	stream := hc.Events(context.Background(), "/", EventStreamConfig{ReconnectDelay: time.Hour})
+	received := make(chan struct{}, 1)
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for stream.Next() {
+			select {
+			case received <- struct{}{}:
+			default:
+			}
+		}
+	}()
+	<-received
+	if err := stream.Close(); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("expected Close stops Next")
+	}
+	if err := stream.Err(); err != nil {
+		t.Fatalf("expected closed stream without error, got: %v", err)
+	}
+}
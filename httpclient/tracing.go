@@ -0,0 +1,70 @@
+package httpclient
+
+import (
+	"fmt"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	semconv "go.opentelemetry.io/otel/semconv/v1.17.0"
+	"go.opentelemetry.io/otel/trace"
+	"net/http"
+)
+
+const tracerName = "github.com/uddmorningsun/go-livingkit/httpclient"
+
+type tracing struct {
+	tracer     trace.Tracer
+	propagator propagation.TextMapPropagator
+	redactor   *Redactor
+}
+
+// WithTracing starts a client span for every request with the context of the request, and propagates trace context
+// with W3C `traceparent` and `tracestate` headers. Nil provider uses otel.GetTracerProvider(), and nil propagator uses
+// propagation.TraceContext. The span covers auth, cache and retries of the request, and ends when response headers
+// are received. Sensitive query params of `http.url` attribute are redacted like WithDebug. In tests, spans can be
+// collected by an in-memory exporter, e.g.:
+//
+//	exporter := tracetest.NewInMemoryExporter()
+//	provider := sdktrace.NewTracerProvider(sdktrace.WithSyncer(exporter))
+//	hc, err := httpclient.NewHTTPClientWithOptions(httpclient.WithTracing(provider, nil))
+func WithTracing(provider trace.TracerProvider, propagator propagation.TextMapPropagator) Option {
+	return func(hc *HTTPClient) error {
+		if provider == nil {
+			provider = otel.GetTracerProvider()
+		}
+		if propagator == nil {
+			propagator = propagation.TraceContext{}
+		}
+		hc.tracing = &tracing{tracer: provider.Tracer(tracerName), propagator: propagator, redactor: NewRedactor(nil, nil)}
+		return nil
+	}
+}
+
+// interceptor starts the client span and injects trace context to request headers.
+func (t *tracing) interceptor(req *http.Request, next Invoker) (*http.Response, error) {
+	u := *req.URL
+	u.User = nil
+	ctx, span := t.tracer.Start(req.Context(), fmt.Sprintf("HTTP %s", req.Method),
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(
+			semconv.HTTPMethodKey.String(req.Method),
+			semconv.HTTPURLKey.String(t.redactor.URL(&u)),
+			semconv.NetPeerNameKey.String(req.URL.Hostname()),
+		),
+	)
+	defer span.End()
+	req = req.WithContext(ctx)
+	t.propagator.Inject(ctx, propagation.HeaderCarrier(req.Header))
+
+	resp, err := next(req)
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+		return resp, err
+	}
+	span.SetAttributes(semconv.HTTPStatusCodeKey.Int(resp.StatusCode))
+	if resp.StatusCode >= http.StatusBadRequest {
+		span.SetStatus(codes.Error, resp.Status)
+	}
+	return resp, nil
+}
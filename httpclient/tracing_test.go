@@ -0,0 +1,55 @@
+package httpclient
+
+import (
+	"context"
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	semconv "go.opentelemetry.io/otel/semconv/v1.17.0"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestTracing(t *testing.T) {
+	traceparent := make(chan string, 2)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		traceparent <- req.Header.Get("traceparent")
+		if req.URL.Path == "/broken" {
+			w.WriteHeader(http.StatusBadGateway)
+		}
+	}))
+	defer server.Close()
+	exporter := tracetest.NewInMemoryExporter()
+	provider := sdktrace.NewTracerProvider(sdktrace.WithSyncer(exporter))
+	hc, err := NewHTTPClientWithOptions(WithAddress(server.URL), WithTracing(provider, nil))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, path := range []string{"/users?access_token=s3cr3t&page=1", "/broken"} {
+		resp, err := hc.GetWithContext(context.Background(), path, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		drainBody(resp)
+	}
+	spans := exporter.GetSpans()
+	if len(spans) != 2 {
+		t.Fatalf("expected 2 spans, got: %d", len(spans))
+	}
+	attributes := map[string]string{}
+	for _, attribute := range spans[0].Attributes {
+		attributes[string(attribute.Key)] = attribute.Value.Emit()
+	}
+	if url := attributes[string(semconv.HTTPURLKey)]; strings.Contains(url, "s3cr3t") || !strings.Contains(url, "page=1") {
+		t.Fatalf("expected sensitive query params are redacted in span, got: %s", url)
+	}
+	if parent := <-traceparent; !strings.Contains(parent, spans[0].SpanContext.TraceID().String()) {
+		t.Fatalf("expected trace context is propagated, got: %s", parent)
+	}
+	if spans[0].Status.Code == codes.Error || spans[1].Status.Code != codes.Error {
+		t.Fatalf("expected error status of 5xx only, got: %v, %v", spans[0].Status, spans[1].Status)
+	}
+}
@@ -0,0 +1,208 @@
+package httpclient
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"net/http"
+	urllib "net/url"
+	"os"
+	"time"
+)
+
+// newDefaultClient returns an isolated http.Client with its own transport cloned from http.DefaultTransport, so that
+// options will not change the process-global http.DefaultClient.
+func newDefaultClient() *http.Client {
+	return &http.Client{Transport: http.DefaultTransport.(*http.Transport).Clone()}
+}
+
+// transport returns the underlying *http.Transport, it returns error if the transport is replaced by WithTransport.
+func (hc *HTTPClient) transport() (*http.Transport, error) {
+	transport, ok := hc.client.Transport.(*http.Transport)
+	if !ok {
+		return nil, fmt.Errorf("transport option requires *http.Transport, but got: %T", hc.client.Transport)
+	}
+	return transport, nil
+}
+
+func withTransport(fn func(transport *http.Transport) error) Option {
+	return func(hc *HTTPClient) error {
+		transport, err := hc.transport()
+		if err != nil {
+			return err
+		}
+		return fn(transport)
+	}
+}
+
+func withTLSConfig(fn func(cfg *tls.Config) error) Option {
+	return withTransport(func(transport *http.Transport) error {
+		if transport.TLSClientConfig == nil {
+			transport.TLSClientConfig = &tls.Config{}
+		}
+		return fn(transport.TLSClientConfig)
+	})
+}
+
+// WithTransport replaces the underlying transport, e.g.: a record/replay transport for testing.
+// Transport options (e.g.: WithProxy) can't be applied after it unless the given one is *http.Transport.
+func WithTransport(rt http.RoundTripper) Option {
+	return func(hc *HTTPClient) error {
+		if rt == nil {
+			return fmt.Errorf("nil transport")
+		}
+		hc.client.Transport = rt
+		return nil
+	}
+}
+
+// WithTimeout sets timeout of the whole request including reading response body, zero means no timeout.
+func WithTimeout(timeout time.Duration) Option {
+	return func(hc *HTTPClient) error {
+		if timeout < 0 {
+			return fmt.Errorf("timeout should not be negative")
+		}
+		hc.client.Timeout = timeout
+		return nil
+	}
+}
+
+// WithMaxIdleConns sets the maximum idle connections across all hosts, zero means no limit.
+func WithMaxIdleConns(n int) Option {
+	return withTransport(func(transport *http.Transport) error {
+		transport.MaxIdleConns = n
+		return nil
+	})
+}
+
+// WithMaxIdleConnsPerHost sets the maximum idle connections of each host, zero means http.DefaultMaxIdleConnsPerHost.
+func WithMaxIdleConnsPerHost(n int) Option {
+	return withTransport(func(transport *http.Transport) error {
+		transport.MaxIdleConnsPerHost = n
+		return nil
+	})
+}
+
+// WithMaxConnsPerHost limits the total connections (dialing, active and idle) of each host, zero means no limit.
+func WithMaxConnsPerHost(n int) Option {
+	return withTransport(func(transport *http.Transport) error {
+		transport.MaxConnsPerHost = n
+		return nil
+	})
+}
+
+// WithIdleConnTimeout sets how long an idle connection keeps in the pool, zero means no limit.
+func WithIdleConnTimeout(timeout time.Duration) Option {
+	return withTransport(func(transport *http.Transport) error {
+		transport.IdleConnTimeout = timeout
+		return nil
+	})
+}
+
+// WithTLSHandshakeTimeout sets the timeout of TLS handshake, zero means no timeout.
+func WithTLSHandshakeTimeout(timeout time.Duration) Option {
+	return withTransport(func(transport *http.Transport) error {
+		transport.TLSHandshakeTimeout = timeout
+		return nil
+	})
+}
+
+// WithResponseHeaderTimeout sets the timeout of waiting response headers after request is written, zero means no timeout.
+func WithResponseHeaderTimeout(timeout time.Duration) Option {
+	return withTransport(func(transport *http.Transport) error {
+		transport.ResponseHeaderTimeout = timeout
+		return nil
+	})
+}
+
+// WithProxy sets the proxy URL for all requests, empty proxy disables the default proxy from environment
+// (HTTP_PROXY, HTTPS_PROXY and NO_PROXY).
+func WithProxy(proxy string) Option {
+	return withTransport(func(transport *http.Transport) error {
+		if proxy == "" {
+			transport.Proxy = nil
+			return nil
+		}
+		u, err := urllib.Parse(proxy)
+		if err != nil {
+			return fmt.Errorf("unable to parse proxy, error: %s", err)
+		}
+		transport.Proxy = http.ProxyURL(u)
+		return nil
+	})
+}
+
+// WithTLSConfig sets TLS config of the transport, the config is cloned.
+func WithTLSConfig(cfg *tls.Config) Option {
+	return withTransport(func(transport *http.Transport) error {
+		if cfg == nil {
+			return fmt.Errorf("nil TLS config")
+		}
+		transport.TLSClientConfig = cfg.Clone()
+		return nil
+	})
+}
+
+// WithRootCAs trusts the given PEM encoded CA certificates in addition to system ones.
+func WithRootCAs(pemCerts []byte) Option {
+	return withTLSConfig(func(cfg *tls.Config) error {
+		pool := cfg.RootCAs
+		if pool == nil {
+			systemPool, err := x509.SystemCertPool()
+			if err != nil {
+				systemPool = x509.NewCertPool()
+			}
+			pool = systemPool
+		}
+		if !pool.AppendCertsFromPEM(pemCerts) {
+			return fmt.Errorf("no valid PEM certificate found")
+		}
+		cfg.RootCAs = pool
+		return nil
+	})
+}
+
+// WithRootCAFile trusts CA certificates of the given PEM file in addition to system ones.
+func WithRootCAFile(file string) Option {
+	return func(hc *HTTPClient) error {
+		pemCerts, err := os.ReadFile(file)
+		if err != nil {
+			return fmt.Errorf("unable to read CA file, error: %s", err)
+		}
+		return WithRootCAs(pemCerts)(hc)
+	}
+}
+
+// WithClientCertificate sets the client certificate for mutual TLS.
+func WithClientCertificate(certFile, keyFile string) Option {
+	return withTLSConfig(func(cfg *tls.Config) error {
+		cert, err := tls.LoadX509KeyPair(certFile, keyFile)
+		if err != nil {
+			return fmt.Errorf("unable to load client certificate, error: %s", err)
+		}
+		cfg.Certificates = append(cfg.Certificates, cert)
+		return nil
+	})
+}
+
+// WithMinTLSVersion sets the minimum TLS version, e.g.: tls.VersionTLS12.
+func WithMinTLSVersion(version uint16) Option {
+	return withTLSConfig(func(cfg *tls.Config) error {
+		cfg.MinVersion = version
+		return nil
+	})
+}
+
+// WithHTTP2 enables or disables HTTP/2, it's enabled by default.
+func WithHTTP2(enabled bool) Option {
+	return withTransport(func(transport *http.Transport) error {
+		transport.ForceAttemptHTTP2 = enabled
+		if !enabled {
+			// Non-nil empty map disables HTTP/2, see net/http/doc.go.
+			transport.TLSNextProto = make(map[string]func(string, *tls.Conn) http.RoundTripper)
+		} else {
+			transport.TLSNextProto = nil
+		}
+		return nil
+	})
+}
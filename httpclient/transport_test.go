@@ -0,0 +1,121 @@
+package httpclient
+
+import (
+	"context"
+	"crypto/tls"
+	"encoding/pem"
+	"io"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func clientTransport(t *testing.T, hc *HTTPClient) *http.Transport {
+	t.Helper()
+	transport, err := hc.transport()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return transport
+}
+
+func TestClientTransportIsolated(t *testing.T) {
+	defaultTransport := http.DefaultTransport.(*http.Transport)
+	first, err := NewHTTPClientWithOptions(WithProxy(""), WithMaxConnsPerHost(3), WithHTTP2(false))
+	if err != nil {
+		t.Fatal(err)
+	}
+	second, err := NewHTTPClientWithOptions()
+	if err != nil {
+		t.Fatal(err)
+	}
+	firstTransport, secondTransport := clientTransport(t, first), clientTransport(t, second)
+	if firstTransport == defaultTransport || secondTransport == defaultTransport || firstTransport == secondTransport {
+		t.Fatal("expected each client has its own transport rather than http.DefaultTransport")
+	}
+	if defaultTransport.Proxy == nil || defaultTransport.MaxConnsPerHost != 0 || !defaultTransport.ForceAttemptHTTP2 {
+		t.Fatal("expected http.DefaultTransport is not changed by transport options")
+	}
+	if secondTransport.Proxy == nil || secondTransport.MaxConnsPerHost != 0 || !secondTransport.ForceAttemptHTTP2 {
+		t.Fatal("expected the other client is not changed by transport options")
+	}
+}
+
+func TestTransportOptions(t *testing.T) {
+	cfg := &tls.Config{ServerName: "api"}
+	hc, err := NewHTTPClientWithOptions(
+		WithTimeout(time.Minute),
+		WithMaxIdleConns(10),
+		WithMaxIdleConnsPerHost(5),
+		WithMaxConnsPerHost(8),
+		WithIdleConnTimeout(time.Second),
+		WithTLSHandshakeTimeout(2*time.Second),
+		WithResponseHeaderTimeout(3*time.Second),
+		WithProxy("http://proxy:3128"),
+		WithTLSConfig(cfg),
+		WithMinTLSVersion(tls.VersionTLS12),
+		WithHTTP2(false),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cfg.ServerName = "changed"
+	transport := clientTransport(t, hc)
+	if hc.client.Timeout != time.Minute || transport.MaxIdleConns != 10 || transport.MaxIdleConnsPerHost != 5 ||
+		transport.MaxConnsPerHost != 8 || transport.IdleConnTimeout != time.Second ||
+		transport.TLSHandshakeTimeout != 2*time.Second || transport.ResponseHeaderTimeout != 3*time.Second {
+		t.Fatalf("expected timeouts and connection limits are set, got: %+v", transport)
+	}
+	proxy, err := transport.Proxy(httptest.NewRequest(http.MethodGet, "http://api/", nil))
+	if err != nil || proxy.String() != "http://proxy:3128" {
+		t.Fatalf("expected proxy is set, got: %v, %v", proxy, err)
+	}
+	if transport.TLSClientConfig.ServerName != "api" || transport.TLSClientConfig.MinVersion != tls.VersionTLS12 {
+		t.Fatalf("expected TLS config is cloned, got: %+v", transport.TLSClientConfig)
+	}
+	if transport.ForceAttemptHTTP2 || transport.TLSNextProto == nil || len(transport.TLSNextProto) != 0 {
+		t.Fatal("expected HTTP/2 is disabled")
+	}
+
+	if _, err := NewHTTPClientWithOptions(WithRootCAs([]byte("invalid"))); err == nil {
+		t.Fatal("expected error of invalid PEM certificate")
+	}
+	if _, err := NewHTTPClientWithOptions(WithTransport(unsupportedTransport{}), WithProxy("")); err == nil {
+		t.Fatal("expected error of transport options after non *http.Transport")
+	}
+}
+
+// unsupportedTransport is a http.RoundTripper which is not *http.Transport.
+type unsupportedTransport struct{}
+
+func (unsupportedTransport) RoundTrip(*http.Request) (*http.Response, error) {
+	return nil, http.ErrNotSupported
+}
+
+func TestWithRootCAs(t *testing.T) {
+	server := httptest.NewUnstartedServer(http.HandlerFunc(func(http.ResponseWriter, *http.Request) {}))
+	// Handshake error of the untrusted client is expected.
+	server.Config.ErrorLog = log.New(io.Discard, "", 0)
+	server.StartTLS()
+	defer server.Close()
+	pemCert := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: server.Certificate().Raw})
+
+	untrusted, err := NewHTTPClientWithOptions(WithAddress(server.URL))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := untrusted.GetWithContext(context.Background(), "/", nil); err == nil {
+		t.Fatal("expected error of unknown certificate authority")
+	}
+	trusted, err := NewHTTPClientWithOptions(WithAddress(server.URL), WithRootCAs(pemCert))
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp, err := trusted.GetWithContext(context.Background(), "/", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	drainBody(resp)
+}
@@ -0,0 +1,160 @@
+// https://datatracker.ietf.org/doc/html/rfc6749#section-4.4
+package clientcredentials
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	urllib "net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	defaultRefreshBefore = 30 * time.Second
+	defaultTimeout       = 10 * time.Second
+)
+
+// Token is the access token response of token endpoint, see: https://datatracker.ietf.org/doc/html/rfc6749#section-5.1
+type Token struct {
+	AccessToken string `json:"access_token"`
+	TokenType   string `json:"token_type"`
+	ExpiresIn   int64  `json:"expires_in"`
+	Scope       string `json:"scope,omitempty"`
+	// Expiry is computed by ExpiresIn when token is received, zero means never expires.
+	Expiry time.Time `json:"-"`
+}
+
+// Type returns the token type for `Authorization` header, default is Bearer.
+func (t *Token) Type() string {
+	if t.TokenType == "" || strings.EqualFold(t.TokenType, "bearer") {
+		return "Bearer"
+	}
+	return t.TokenType
+}
+
+// expired reports whether the token expires within the given leeway.
+func (t *Token) expired(leeway time.Duration) bool {
+	if t.Expiry.IsZero() {
+		return false
+	}
+	return time.Now().Add(leeway).After(t.Expiry)
+}
+
+// tokenError is the error response of token endpoint, see: https://datatracker.ietf.org/doc/html/rfc6749#section-5.2
+type tokenError struct {
+	Error            string `json:"error"`
+	ErrorDescription string `json:"error_description"`
+}
+
+// Config describes a client credentials flow.
+type Config struct {
+	TokenURL     string
+	ClientID     string
+	ClientSecret string
+	Scopes       []string
+	// EndpointParams are additional params sent to token endpoint, e.g.: `audience`.
+	EndpointParams urllib.Values
+	// AuthInHeader sends client credentials with HTTP basic auth instead of request body.
+	AuthInHeader bool
+	// RefreshBefore refreshes token before it expires, default is 30s.
+	RefreshBefore time.Duration
+	// HTTPClient is used to request token endpoint, default is a client with 10s timeout.
+	HTTPClient *http.Client
+}
+
+// TokenSource returns a TokenSource which caches the token and refreshes it before expiry.
+func (c *Config) TokenSource() *TokenSource {
+	return &TokenSource{cfg: c}
+}
+
+// TokenSource caches token of the client credentials flow, it's safe for concurrent use.
+type TokenSource struct {
+	cfg   *Config
+	mu    sync.Mutex
+	token *Token
+}
+
+// Token returns the cached token, or requests a new one if there is no token or it's about to expire.
+func (ts *TokenSource) Token(ctx context.Context) (*Token, error) {
+	ts.mu.Lock()
+	defer ts.mu.Unlock()
+	refreshBefore := ts.cfg.RefreshBefore
+	if refreshBefore <= 0 {
+		refreshBefore = defaultRefreshBefore
+	}
+	if ts.token != nil && !ts.token.expired(refreshBefore) {
+		return ts.token, nil
+	}
+	token, err := ts.cfg.retrieveToken(ctx)
+	if err != nil {
+		return nil, err
+	}
+	ts.token = token
+	return token, nil
+}
+
+// Invalidate drops the cached token, e.g.: resource server responds 401 with the token.
+func (ts *TokenSource) Invalidate() {
+	ts.mu.Lock()
+	defer ts.mu.Unlock()
+	ts.token = nil
+}
+
+func (c *Config) retrieveToken(ctx context.Context) (*Token, error) {
+	params := urllib.Values{"grant_type": {"client_credentials"}}
+	for key, values := range c.EndpointParams {
+		params[key] = values
+	}
+	if len(c.Scopes) > 0 {
+		params.Set("scope", strings.Join(c.Scopes, " "))
+	}
+	if !c.AuthInHeader {
+		params.Set("client_id", c.ClientID)
+		params.Set("client_secret", c.ClientSecret)
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, c.TokenURL, strings.NewReader(params.Encode()))
+	if err != nil {
+		return nil, fmt.Errorf("unable to initialize token request, error: %s", err)
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Accept", "application/json")
+	if c.AuthInHeader {
+		req.SetBasicAuth(urllib.QueryEscape(c.ClientID), urllib.QueryEscape(c.ClientSecret))
+	}
+
+	client := c.HTTPClient
+	if client == nil {
+		client = &http.Client{Timeout: defaultTimeout}
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("token request failed, error: %w", err)
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if err != nil {
+		return nil, fmt.Errorf("unable to read token response, error: %s", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		var te tokenError
+		if err := json.Unmarshal(body, &te); err == nil && te.Error != "" {
+			return nil, fmt.Errorf("token request failed with status: %s, error: %s %s", resp.Status, te.Error, te.ErrorDescription)
+		}
+		return nil, fmt.Errorf("token request failed with status: %s", resp.Status)
+	}
+	var token Token
+	if err := json.Unmarshal(body, &token); err != nil {
+		return nil, fmt.Errorf("unable to unmarshal token response, error: %s", err)
+	}
+	if token.AccessToken == "" {
+		return nil, fmt.Errorf("no access token found in token response")
+	}
+	if token.ExpiresIn > 0 {
+		token.Expiry = time.Now().Add(time.Duration(token.ExpiresIn) * time.Second)
+	}
+	return &token, nil
+}
@@ -0,0 +1,115 @@
+package clientcredentials
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	urllib "net/url"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestTokenSourceCachesToken(t *testing.T) {
+	var calls int32
+	var form urllib.Values
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		if err := req.ParseForm(); err != nil {
+			t.Error(err)
+		}
+		form = req.PostForm
+		fmt.Fprintf(w, `{"access_token": "token-%d", "token_type": "bearer", "expires_in": %d}`, atomic.AddInt32(&calls, 1), 60)
+	}))
+	defer server.Close()
+
+	cfg := &Config{
+		TokenURL:       server.URL,
+		ClientID:       "id",
+		ClientSecret:   "secret",
+		Scopes:         []string{"read", "write"},
+		EndpointParams: urllib.Values{"audience": {"api"}},
+	}
+	ts := cfg.TokenSource()
+	for i := 0; i < 3; i++ {
+		token, err := ts.Token(context.Background())
+		if err != nil {
+			t.Fatal(err)
+		}
+		if token.AccessToken != "token-1" || token.Type() != "Bearer" {
+			t.Fatalf("expected cached token-1, got: %s %s", token.Type(), token.AccessToken)
+		}
+	}
+	expected := urllib.Values{
+		"grant_type":    {"client_credentials"},
+		"client_id":     {"id"},
+		"client_secret": {"secret"},
+		"scope":         {"read write"},
+		"audience":      {"api"},
+	}
+	if form.Encode() != expected.Encode() {
+		t.Fatalf("expected token request params: %s, got: %s", expected.Encode(), form.Encode())
+	}
+
+	ts.Invalidate()
+	if token, err := ts.Token(context.Background()); err != nil || token.AccessToken != "token-2" {
+		t.Fatalf("expected new token after Invalidate, got: %v, %v", token, err)
+	}
+	// Token expires within RefreshBefore is refreshed.
+	cfg.RefreshBefore = 2 * time.Minute
+	if token, err := ts.Token(context.Background()); err != nil || token.AccessToken != "token-3" {
+		t.Fatalf("expected token is refreshed before expiry, got: %v, %v", token, err)
+	}
+}
+
+func TestTokenSourceAuthInHeader(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		username, password, ok := req.BasicAuth()
+		if !ok || username != "my%3Aid" || password != "p%40ss" || req.PostFormValue("client_secret") != "" {
+			w.WriteHeader(http.StatusUnauthorized)
+			fmt.Fprint(w, `{"error": "invalid_client", "error_description": "bad credentials"}`)
+			return
+		}
+		fmt.Fprint(w, `{"access_token": "token", "token_type": "mac"}`)
+	}))
+	defer server.Close()
+
+	token, err := (&Config{TokenURL: server.URL, ClientID: "my:id", ClientSecret: "p@ss", AuthInHeader: true}).
+		TokenSource().Token(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if token.Type() != "mac" || !token.Expiry.IsZero() {
+		t.Fatalf("expected mac token which never expires, got: %s, %s", token.Type(), token.Expiry)
+	}
+	_, err = (&Config{TokenURL: server.URL, ClientID: "my:id", ClientSecret: "p@ss"}).TokenSource().Token(context.Background())
+	if err == nil || !strings.Contains(err.Error(), "invalid_client bad credentials") {
+		t.Fatalf("expected error response of token endpoint, got: %v", err)
+	}
+}
+
+func TestTokenSourceInvalidResponse(t *testing.T) {
+	cases := map[string]struct {
+		statusCode int
+		body       string
+		expected   string
+	}{
+		"status":       {statusCode: http.StatusBadGateway, body: "bad gateway", expected: "502 Bad Gateway"},
+		"json":         {statusCode: http.StatusOK, body: "{", expected: "unable to unmarshal"},
+		"access token": {statusCode: http.StatusOK, body: `{"token_type": "bearer"}`, expected: "no access token"},
+	}
+	for name, c := range cases {
+		t.Run(name, func(t *testing.T) {
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+				w.WriteHeader(c.statusCode)
+				fmt.Fprint(w, c.body)
+			}))
+			defer server.Close()
+			_, err := (&Config{TokenURL: server.URL}).TokenSource().Token(context.Background())
+			if err == nil || !strings.Contains(err.Error(), c.expected) {
+				t.Fatalf("expected error: %s, got: %v", c.expected, err)
+			}
+		})
+	}
+}
@@ -1,27 +1,384 @@
 package livingkit
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"github.com/sirupsen/logrus"
+	"math"
+	"math/rand"
 	"time"
 )
 
+// Jitter decides how much randomness is applied to the computed backoff delay, see:
+// https://aws.amazon.com/blogs/architecture/exponential-backoff-and-jitter/
+type Jitter int
+
+const (
+	// NoJitter sleeps exactly the computed backoff delay.
+	NoJitter Jitter = iota
+	// FullJitter sleeps a random duration in [0, delay).
+	FullJitter
+	// EqualJitter sleeps delay/2 plus a random duration in [0, delay/2).
+	EqualJitter
+	// DecorrelatedJitter sleeps a random duration in [InitialInterval, previous delay * 3).
+	DecorrelatedJitter
+)
+
+// RetryPolicy describes how a function will be retried, see DefaultRetryPolicy for a sensible one.
+type RetryPolicy struct {
+	// MaxAttempts is the total times (including the first call) to call the function, zero or negative means unlimited
+	// and the loop is bounded by MaxElapsedTime or the context only.
+	MaxAttempts int
+	// InitialInterval is the delay before the first retry.
+	InitialInterval time.Duration
+	// MaxInterval caps the delay between two attempts, zero means no cap. Retrying stops if the delay suggested by
+	// RetryAfter exceeds it.
+	MaxInterval time.Duration
+	// Multiplier grows the delay after each failed attempt, 1 means fixed delay.
+	Multiplier float64
+	Jitter     Jitter
+	// MaxElapsedTime stops retrying once the total elapsed time (including the next delay) exceeds it, zero means no limit.
+	MaxElapsedTime time.Duration
+	// AttemptTimeout gives each attempt its own context deadline, zero means attempt shares the caller context.
+	AttemptTimeout time.Duration
+	// Classifier reports whether the error is worth retrying, nil means every error except Permanent one is retryable.
+	Classifier func(err error) bool
+
+	// Name identifies the retried operation (e.g.: downstream dependency) in RetryEvent and RetryMetrics.
+	Name string
+	// OnRetry is called after a failed attempt which will be retried after RetryEvent.Delay.
+	OnRetry func(event RetryEvent)
+	// OnGiveUp is called once when retry stops with error.
+	OnGiveUp func(event RetryEvent)
+	// OnSuccess is called once when an attempt succeeds.
+	OnSuccess func(event RetryEvent)
+	// Metrics is an optional sink of attempts and total latency.
+	Metrics RetryMetrics
+}
+
+// RetryOutcome is the result of an attempt or of the whole retry loop.
+type RetryOutcome string
+
+const (
+	RetryOutcomeSuccess RetryOutcome = "success"
+	RetryOutcomeRetry   RetryOutcome = "retry"
+	RetryOutcomeGiveUp  RetryOutcome = "give_up"
+)
+
+// RetryEvent describes an attempt for the observability hooks of RetryPolicy.
+type RetryEvent struct {
+	Name    string
+	Attempt int
+	// Delay is the wait before next attempt, it's always zero in OnGiveUp and OnSuccess.
+	Delay time.Duration
+	// Err is the attempt error for OnRetry, the final *RetryError for OnGiveUp and nil for OnSuccess.
+	Err error
+	// Elapsed is the time since the first attempt started.
+	Elapsed time.Duration
+}
+
+// RetryMetrics is a counters/histogram sink of RetryPolicy, implementations must be safe for concurrent use.
+type RetryMetrics interface {
+	// IncAttempt counts every attempt with RetryOutcomeSuccess, RetryOutcomeRetry or RetryOutcomeGiveUp.
+	IncAttempt(name string, outcome RetryOutcome)
+	// ObserveLatency records total latency and attempts of the retry loop with RetryOutcomeSuccess or RetryOutcomeGiveUp.
+	ObserveLatency(name string, outcome RetryOutcome, attempts int, elapsed time.Duration)
+}
+
+type permanentError struct {
+	err error
+}
+
+func (e *permanentError) Error() string {
+	return e.err.Error()
+}
+
+func (e *permanentError) Unwrap() error {
+	return e.err
+}
+
+// Permanent wraps the given error to stop the retry loop right away, e.g.: validation error which will never succeed.
+func Permanent(err error) error {
+	if err == nil {
+		return nil
+	}
+	return &permanentError{err: err}
+}
+
+// IsPermanent checks the given error whether is wrapped by Permanent or not.
+func IsPermanent(err error) bool {
+	var pe *permanentError
+	return errors.As(err, &pe)
+}
+
+type retryAfterError struct {
+	err   error
+	delay time.Duration
+}
+
+func (e *retryAfterError) Error() string {
+	return fmt.Sprintf("%s (retry after %s)", e.err, e.delay.String())
+}
+
+func (e *retryAfterError) Unwrap() error {
+	return e.err
+}
+
+// RetryAfter wraps the given error with a delay suggested by server (e.g.: `Retry-After` header), the delay is used
+// before next attempt instead of the computed backoff, or gives up if it exceeds RetryPolicy.MaxInterval.
+func RetryAfter(err error, d time.Duration) error {
+	if err == nil {
+		return nil
+	}
+	return &retryAfterError{err: err, delay: d}
+}
+
+// RetryAfterDelay returns the delay given by RetryAfter if the error is wrapped by it.
+func RetryAfterDelay(err error) (time.Duration, bool) {
+	var rae *retryAfterError
+	if errors.As(err, &rae) {
+		return rae.delay, true
+	}
+	return 0, false
+}
+
+// RetryError is the final error returned when retry gives up, Errors contains error of each attempt in order, and the
+// context error will be the last one if retry is aborted by context.
+// Both errors.Is and errors.As will check against every error in Errors.
+type RetryError struct {
+	Attempts int
+	Errors   []error
+}
+
+func (e *RetryError) Error() string {
+	if len(e.Errors) == 0 {
+		return "retry failed without any attempt"
+	}
+	return fmt.Sprintf("retry failed after %d attempt(s), last error: %s", e.Attempts, e.Errors[len(e.Errors)-1])
+}
+
+// Unwrap returns the last error.
+func (e *RetryError) Unwrap() error {
+	if len(e.Errors) == 0 {
+		return nil
+	}
+	return e.Errors[len(e.Errors)-1]
+}
+
+// Is reports whether any attempt error matches target.
+func (e *RetryError) Is(target error) bool {
+	for _, err := range e.Errors {
+		if errors.Is(err, target) {
+			return true
+		}
+	}
+	return false
+}
+
+// As finds the first attempt error that matches target.
+func (e *RetryError) As(target interface{}) bool {
+	for _, err := range e.Errors {
+		if errors.As(err, target) {
+			return true
+		}
+	}
+	return false
+}
+
+// DefaultRetryPolicy returns a policy with 3 attempts, exponential backoff from 100ms to 10s and full jitter.
+func DefaultRetryPolicy() RetryPolicy {
+	return RetryPolicy{
+		MaxAttempts:     3,
+		InitialInterval: 100 * time.Millisecond,
+		MaxInterval:     10 * time.Second,
+		Multiplier:      2,
+		Jitter:          FullJitter,
+	}
+}
+
+// backoff returns the delay before next attempt, `previous` is the delay used before current attempt.
+func (p RetryPolicy) backoff(retry int, previous time.Duration) time.Duration {
+	if p.Jitter == DecorrelatedJitter {
+		upper := previous * 3
+		if upper <= p.InitialInterval {
+			return p.capInterval(p.InitialInterval)
+		}
+		return p.capInterval(p.InitialInterval + time.Duration(rand.Int63n(int64(upper-p.InitialInterval))))
+	}
+
+	if p.InitialInterval <= 0 {
+		return 0
+	}
+	multiplier := p.Multiplier
+	// NaN multiplier is treated as 1 as well.
+	if !(multiplier >= 1) {
+		multiplier = 1
+	}
+	delay := float64(p.InitialInterval) * math.Pow(multiplier, float64(retry-1))
+	// float64(math.MaxInt64) rounds up to 2^63 which overflows time.Duration, so the delay is converted only below it.
+	interval := time.Duration(math.MaxInt64)
+	if delay < math.MaxInt64 {
+		interval = time.Duration(delay)
+	}
+	interval = p.capInterval(interval)
+	switch p.Jitter {
+	case FullJitter:
+		return time.Duration(rand.Int63n(int64(interval)))
+	case EqualJitter:
+		half := interval / 2
+		if half <= 0 {
+			return interval
+		}
+		return half + time.Duration(rand.Int63n(int64(half)))
+	}
+	return interval
+}
+
+func (p RetryPolicy) capInterval(interval time.Duration) time.Duration {
+	if p.MaxInterval > 0 && interval > p.MaxInterval {
+		return p.MaxInterval
+	}
+	return interval
+}
+
+// Do calls retryFunc until it succeeds, attempts are exhausted, MaxElapsedTime is reached, error is not retryable or
+// ctx is done. The function will not sleep over the deadline of ctx, it gives up immediately if the next delay would pass it.
+// The returned error is a *RetryError which wraps error of every attempt.
+func (p RetryPolicy) Do(ctx context.Context, retryFunc func(ctx context.Context) error) error {
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	var (
+		errs        []error
+		delay       time.Duration
+		startedTime = time.Now()
+	)
+	// giveUp builds the final error, ctxErr is not nil if retry is aborted by context. countAttempt is false if there is
+	// no attempt or last attempt has been counted as RetryOutcomeRetry already.
+	giveUp := func(ctxErr error, countAttempt bool) error {
+		retryErr := &RetryError{Attempts: len(errs), Errors: errs}
+		if ctxErr != nil {
+			retryErr.Errors = append(retryErr.Errors, ctxErr)
+		}
+		p.observe(p.OnGiveUp, RetryOutcomeGiveUp, countAttempt, len(errs), 0, retryErr, startedTime)
+		return retryErr
+	}
+	for attempt := 1; ; attempt++ {
+		if ctxErr := ctx.Err(); ctxErr != nil {
+			return giveUp(ctxErr, false)
+		}
+		err := p.call(ctx, retryFunc)
+		if err == nil {
+			p.observe(p.OnSuccess, RetryOutcomeSuccess, true, attempt, 0, nil, startedTime)
+			return nil
+		}
+		errs = append(errs, err)
+		if !p.retryable(err) {
+			return giveUp(nil, true)
+		}
+		if p.MaxAttempts > 0 && attempt >= p.MaxAttempts {
+			return giveUp(nil, true)
+		}
+
+		if suggested, ok := RetryAfterDelay(err); ok {
+			// Retrying before the suggested delay would be rejected again, so give up rather than sleeping over MaxInterval.
+			if p.MaxInterval > 0 && suggested > p.MaxInterval {
+				return giveUp(nil, true)
+			}
+			delay = suggested
+		} else {
+			delay = p.backoff(attempt, delay)
+		}
+		if p.MaxElapsedTime > 0 && time.Since(startedTime)+delay > p.MaxElapsedTime {
+			return giveUp(nil, true)
+		}
+		if deadline, ok := ctx.Deadline(); ok && time.Now().Add(delay).After(deadline) {
+			return giveUp(context.DeadlineExceeded, true)
+		}
+		logrus.Debugf("call func failed since error: %s, will retry (%d) after %s", err, attempt, delay.String())
+		p.observe(p.OnRetry, RetryOutcomeRetry, true, attempt, delay, err, startedTime)
+		if ctxErr := sleepContext(ctx, delay); ctxErr != nil {
+			return giveUp(ctxErr, false)
+		}
+	}
+}
+
+// observe calls the hook and records metrics, the latency is only recorded for RetryOutcomeSuccess and RetryOutcomeGiveUp.
+func (p RetryPolicy) observe(
+	hook func(RetryEvent), outcome RetryOutcome, countAttempt bool, attempt int, delay time.Duration, err error, startedTime time.Time,
+) {
+	elapsed := time.Since(startedTime)
+	if p.Metrics != nil {
+		if countAttempt {
+			p.Metrics.IncAttempt(p.Name, outcome)
+		}
+		if outcome != RetryOutcomeRetry {
+			p.Metrics.ObserveLatency(p.Name, outcome, attempt, elapsed)
+		}
+	}
+	if hook != nil {
+		hook(RetryEvent{Name: p.Name, Attempt: attempt, Delay: delay, Err: err, Elapsed: elapsed})
+	}
+}
+
+func (p RetryPolicy) retryable(err error) bool {
+	if IsPermanent(err) {
+		return false
+	}
+	if p.Classifier != nil {
+		return p.Classifier(err)
+	}
+	return true
+}
+
+func (p RetryPolicy) call(ctx context.Context, retryFunc func(ctx context.Context) error) error {
+	if p.AttemptTimeout <= 0 {
+		return retryFunc(ctx)
+	}
+	attemptCtx, cancel := context.WithTimeout(ctx, p.AttemptTimeout)
+	defer cancel()
+	return retryFunc(attemptCtx)
+}
+
+// sleepContext pauses the current goroutine for at least the duration d or returns earlier if ctx is done.
+func sleepContext(ctx context.Context, d time.Duration) error {
+	if d <= 0 {
+		return ctx.Err()
+	}
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
+
 // Retrying supports retry behaviour especially HTTP request and task operation.
+// It's a thin wrapper of RetryPolicy with fixed delay, use RetryPolicy.Do for backoff, jitter and cancellation.
+// retryFunc can return Permanent(err) to stop retrying or RetryAfter(err, d) to overwrite the fixed delay.
 func Retrying(retryTimes int, sleepTimes time.Duration, retryFunc func() error) error {
 	if retryTimes < 0 {
 		return fmt.Errorf("invalid param, 'retryTimes' should be greater than 0")
 	}
-	var err = fmt.Errorf("retry error since oversize default times: %d", retryTimes)
-	for i := 1; i <= retryTimes; i++ {
-		if err = retryFunc(); err != nil {
-			logrus.Warningf(
-				"call func failed since error: %s, will retry total times (%d/%d) times after %s",
-				err, i, retryTimes, sleepTimes.String(),
-			)
-			time.Sleep(sleepTimes)
-			continue
-		}
-		return nil
+	// Zero MaxAttempts of RetryPolicy means unlimited, so zero retryTimes keeps the behaviour of not calling retryFunc.
+	if retryTimes == 0 {
+		return fmt.Errorf("retry error since oversize default times: %d", retryTimes)
+	}
+	policy := RetryPolicy{
+		MaxAttempts:     retryTimes,
+		InitialInterval: sleepTimes,
+		Multiplier:      1,
+	}
+	policy.OnRetry = func(event RetryEvent) {
+		logrus.Warningf(
+			"call func failed since error: %s, will retry total times (%d/%d) times after %s",
+			event.Err, event.Attempt, retryTimes, event.Delay.String(),
+		)
 	}
-	return err
+	return policy.Do(context.Background(), func(context.Context) error {
+		return retryFunc()
+	})
 }
@@ -0,0 +1,246 @@
+package livingkit
+
+import (
+	"context"
+	"errors"
+	"math"
+	"sync"
+	"testing"
+	"time"
+)
+
+var errAttempt = errors.New("attempt failed")
+
+func TestRetryPolicyDoStopsAfterMaxAttempts(t *testing.T) {
+	calls := 0
+	err := RetryPolicy{MaxAttempts: 3}.Do(context.Background(), func(context.Context) error {
+		calls++
+		return errAttempt
+	})
+	var retryErr *RetryError
+	if !errors.As(err, &retryErr) {
+		t.Fatalf("expected *RetryError, got: %v", err)
+	}
+	if calls != 3 || retryErr.Attempts != 3 || len(retryErr.Errors) != 3 {
+		t.Fatalf("expected 3 attempts, got calls: %d, error: %v", calls, retryErr)
+	}
+	if !errors.Is(err, errAttempt) {
+		t.Fatalf("expected error wraps attempt error, got: %v", err)
+	}
+}
+
+func TestRetryPolicyDoSucceeds(t *testing.T) {
+	calls := 0
+	err := RetryPolicy{MaxAttempts: 5}.Do(context.Background(), func(context.Context) error {
+		calls++
+		if calls < 3 {
+			return errAttempt
+		}
+		return nil
+	})
+	if err != nil || calls != 3 {
+		t.Fatalf("expected success at attempt 3, got calls: %d, error: %v", calls, err)
+	}
+}
+
+func TestRetryPolicyDoPermanent(t *testing.T) {
+	calls := 0
+	err := RetryPolicy{MaxAttempts: 5}.Do(context.Background(), func(context.Context) error {
+		calls++
+		return Permanent(errAttempt)
+	})
+	if calls != 1 || !errors.Is(err, errAttempt) {
+		t.Fatalf("expected permanent error stops at attempt 1, got calls: %d, error: %v", calls, err)
+	}
+}
+
+func TestRetryPolicyDoRetryAfter(t *testing.T) {
+	var delays []time.Duration
+	policy := RetryPolicy{MaxAttempts: 2, InitialInterval: time.Hour}
+	policy.OnRetry = func(event RetryEvent) {
+		delays = append(delays, event.Delay)
+	}
+	_ = policy.Do(context.Background(), func(context.Context) error {
+		return RetryAfter(errAttempt, time.Millisecond)
+	})
+	if len(delays) != 1 || delays[0] != time.Millisecond {
+		t.Fatalf("expected delay suggested by RetryAfter, got: %v", delays)
+	}
+}
+
+func TestRetryPolicyDoRetryAfterOverMaxInterval(t *testing.T) {
+	var calls int
+	policy := RetryPolicy{MaxAttempts: 3, InitialInterval: time.Millisecond, MaxInterval: time.Second}
+	startedTime := time.Now()
+	err := policy.Do(context.Background(), func(context.Context) error {
+		calls++
+		return RetryAfter(errAttempt, 24*time.Hour)
+	})
+	var retryErr *RetryError
+	if !errors.As(err, &retryErr) || retryErr.Attempts != 1 || calls != 1 {
+		t.Fatalf("expected give up after 1 attempt, got: %v after %d calls", err, calls)
+	}
+	if elapsed := time.Since(startedTime); elapsed > time.Second {
+		t.Fatalf("expected no sleep over MaxInterval, got: %s", elapsed)
+	}
+}
+
+func TestRetryPolicyDoDeadline(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	startedTime := time.Now()
+	err := RetryPolicy{InitialInterval: time.Hour, Multiplier: 1}.Do(ctx, func(context.Context) error {
+		return errAttempt
+	})
+	if !errors.Is(err, context.DeadlineExceeded) || !errors.Is(err, errAttempt) {
+		t.Fatalf("expected deadline and attempt error, got: %v", err)
+	}
+	if elapsed := time.Since(startedTime); elapsed > time.Second {
+		t.Fatalf("expected giving up before sleeping over deadline, elapsed: %s", elapsed)
+	}
+}
+
+func TestRetryPolicyDoAttemptTimeout(t *testing.T) {
+	err := RetryPolicy{MaxAttempts: 2, AttemptTimeout: 10 * time.Millisecond}.Do(context.Background(), func(ctx context.Context) error {
+		<-ctx.Done()
+		return ctx.Err()
+	})
+	var retryErr *RetryError
+	if !errors.As(err, &retryErr) || retryErr.Attempts != 2 || !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("expected 2 timed out attempts, got: %v", err)
+	}
+}
+
+func TestRetryPolicyBackoff(t *testing.T) {
+	policy := RetryPolicy{InitialInterval: 100 * time.Millisecond, MaxInterval: 300 * time.Millisecond, Multiplier: 2}
+	for retry, expected := range []time.Duration{100 * time.Millisecond, 200 * time.Millisecond, 300 * time.Millisecond} {
+		if delay := policy.backoff(retry+1, 0); delay != expected {
+			t.Fatalf("expected delay %s of retry %d, got: %s", expected, retry+1, delay)
+		}
+	}
+	policy.Jitter = FullJitter
+	for i := 0; i < 100; i++ {
+		if delay := policy.backoff(2, 0); delay < 0 || delay >= 200*time.Millisecond {
+			t.Fatalf("expected full jitter delay in [0, 200ms), got: %s", delay)
+		}
+	}
+}
+
+func TestRetryPolicyBackoffOverflow(t *testing.T) {
+	cases := []struct {
+		policy RetryPolicy
+		retry  int
+	}{
+		// The delay is exactly 2^63 which equals float64(math.MaxInt64).
+		{policy: RetryPolicy{InitialInterval: 1 << 32, Multiplier: 2}, retry: 32},
+		{policy: RetryPolicy{InitialInterval: time.Second, Multiplier: 2}, retry: 1 << 20},
+		{policy: RetryPolicy{InitialInterval: time.Second, Multiplier: math.Inf(1)}, retry: 2},
+		{policy: RetryPolicy{InitialInterval: time.Minute, Multiplier: math.NaN()}, retry: 10},
+	}
+	for _, c := range cases {
+		c.policy.MaxInterval = time.Minute
+		if delay := c.policy.backoff(c.retry, 0); delay != time.Minute {
+			t.Fatalf("expected delay is capped by MaxInterval of retry %d, got: %s", c.retry, delay)
+		}
+		c.policy.MaxInterval = 0
+		if delay := c.policy.backoff(c.retry, 0); delay <= 0 {
+			t.Fatalf("expected positive delay without MaxInterval of retry %d, got: %s", c.retry, delay)
+		}
+	}
+	if delay := (RetryPolicy{Multiplier: 2, MaxInterval: time.Minute}).backoff(100, 0); delay != 0 {
+		t.Fatalf("expected no delay without InitialInterval, got: %s", delay)
+	}
+}
+
+type recordedMetrics struct {
+	mu       sync.Mutex
+	attempts map[RetryOutcome]int
+	latency  []int
+}
+
+func (m *recordedMetrics) IncAttempt(_ string, outcome RetryOutcome) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.attempts == nil {
+		m.attempts = make(map[RetryOutcome]int)
+	}
+	m.attempts[outcome]++
+}
+
+func (m *recordedMetrics) ObserveLatency(_ string, _ RetryOutcome, attempts int, _ time.Duration) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.latency = append(m.latency, attempts)
+}
+
+func TestRetryPolicyMetrics(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	cases := []struct {
+		name     string
+		policy   RetryPolicy
+		ctx      context.Context
+		attempts map[RetryOutcome]int
+	}{
+		{
+			name:     "max attempts",
+			policy:   RetryPolicy{MaxAttempts: 3},
+			ctx:      context.Background(),
+			attempts: map[RetryOutcome]int{RetryOutcomeRetry: 2, RetryOutcomeGiveUp: 1},
+		},
+		{
+			name:     "delay over deadline",
+			policy:   RetryPolicy{InitialInterval: time.Hour},
+			ctx:      ctx,
+			attempts: map[RetryOutcome]int{RetryOutcomeGiveUp: 1},
+		},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			metrics := &recordedMetrics{}
+			c.policy.Metrics = metrics
+			calls := 0
+			_ = c.policy.Do(c.ctx, func(context.Context) error {
+				calls++
+				return errAttempt
+			})
+			total := 0
+			for outcome, count := range c.attempts {
+				total += count
+				if metrics.attempts[outcome] != count {
+					t.Fatalf("expected %d attempts of %s, got: %v", count, outcome, metrics.attempts)
+				}
+			}
+			if total != calls {
+				t.Fatalf("expected every attempt is counted once, calls: %d, got: %v", calls, metrics.attempts)
+			}
+			if len(metrics.latency) != 1 || metrics.latency[0] != calls {
+				t.Fatalf("expected latency of %d attempts, got: %v", calls, metrics.latency)
+			}
+		})
+	}
+}
+
+func TestRetrying(t *testing.T) {
+	calls := 0
+	err := Retrying(3, 0, func() error {
+		calls++
+		return errAttempt
+	})
+	if calls != 3 || !errors.Is(err, errAttempt) {
+		t.Fatalf("expected 3 calls, got calls: %d, error: %v", calls, err)
+	}
+
+	calls = 0
+	err = Retrying(0, 0, func() error {
+		calls++
+		return nil
+	})
+	if calls != 0 || err == nil || err.Error() != "retry error since oversize default times: 0" {
+		t.Fatalf("expected zero times without call, got calls: %d, error: %v", calls, err)
+	}
+
+	if err := Retrying(-1, 0, func() error { return nil }); err == nil {
+		t.Fatalf("expected error of negative times")
+	}
+}